@@ -1,20 +1,200 @@
 package v1alpha1
 
-import v1 "k8s.io/api/core/v1"
-
 // Auth contains configuration to set up authentication on the remote Mimir Ruler endpoint
-// There are two supported authentication schemes:
+// There are three supported authentication schemes:
 //   - User/API key
 //   - Token (JWT/bearer)
+//   - OAuth2 client credentials, the operator fetches short-lived tokens from the token endpoint
+//
+// Token has precedence over any other authentication method, then OAuth2, then User/API key
+// The user, the key and the token can either be given directly or read from a Kubernetes Secret
+// Plaintext values have precedence over the values read from Secrets
 //
-// Token has precedence over any other authentication method
-// If the user/API key scheme is selected, the key can either be given directly or through
-// a secretRef pointing to a Kubernetes Secret containing the API key under the field "key"
-// The Token can also be given using a Secret containing the value under the field "token"
+// TLS settings can be added to any of those schemes, or used alone to authenticate with a client certificate
 type Auth struct {
-	User           string                   `json:"user,omitempty"`
-	Key            string                   `json:"key,omitempty"`
-	KeySecretRef   *v1.LocalObjectReference `json:"keySecretRef,omitempty"`
-	Token          string                   `json:"token,omitempty"`
-	TokenSecretRef *v1.LocalObjectReference `json:"tokenSecretRef,omitempty"`
+	User string `json:"user,omitempty"`
+
+	// UserSecretRef reads the user from a Secret (key defaults to "user")
+	UserSecretRef *SecretKeySelector `json:"userSecretRef,omitempty"`
+
+	Key string `json:"key,omitempty"`
+
+	// KeySecretRef reads the API key from a Secret (key defaults to "key")
+	KeySecretRef *SecretKeySelector `json:"keySecretRef,omitempty"`
+
+	Token string `json:"token,omitempty"`
+
+	// TokenSecretRef reads the token from a Secret (key defaults to "token")
+	TokenSecretRef *SecretKeySelector `json:"tokenSecretRef,omitempty"`
+
+	OAuth2 *OAuth2    `json:"oauth2,omitempty"`
+	TLS    *TLSConfig `json:"tls,omitempty"`
+}
+
+// SecretKeySelector references a key of a Secret
+// The Secret is looked up in the namespace of the resource (or in the cluster resource namespace for a
+// ClusterMimirConnection) unless Namespace is set. Referencing a Secret of another namespace is only allowed
+// if a MimirReferenceGrant in that namespace permits it
+type SecretKeySelector struct {
+	// Name of the Secret
+	Name string `json:"name"`
+
+	// Key of the value in the Secret, a default depending on the field is used if it is empty
+	Key string `json:"key,omitempty"`
+
+	// Namespace of the Secret
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// OAuth2 contains the settings of the OAuth2 client credentials flow
+// Tokens are cached by the operator and refreshed before they expire
+type OAuth2 struct {
+	// TokenURL is the URL of the token endpoint
+	TokenURL string `json:"tokenURL"`
+
+	// ClientID is the identifier of the OAuth2 client
+	ClientID string `json:"clientID"`
+
+	// ClientSecret references the key of a Secret containing the secret of the OAuth2 client (key defaults to "clientSecret")
+	ClientSecret SecretKeySelector `json:"clientSecret"`
+
+	// Scopes requested for the token
+	Scopes []string `json:"scopes,omitempty"`
+
+	// EndpointParams are additional parameters sent to the token endpoint
+	EndpointParams map[string]string `json:"endpointParams,omitempty"`
+
+	// TLS settings used to reach the token endpoint, the TLS settings of Mimir are never used with it
+	// The system certificate pool is used if it is not set
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// TLSConfig contains the TLS settings used to connect to the remote endpoint
+// The CA bundle and the client certificate can be read either from a Secret or from a ConfigMap,
+// the private key of the client certificate can only be read from a Secret.
+// Secrets and ConfigMaps are read on every synchronization, so rotated certificates are picked up automatically.
+type TLSConfig struct {
+	// CA is the bundle used to verify the certificate of the remote endpoint (key defaults to "ca.crt")
+	// The system certificate pool is used if it is not set
+	CA *SecretOrConfigMap `json:"ca,omitempty"`
+
+	// Cert is the client certificate presented to the remote endpoint for mTLS (key defaults to "tls.crt")
+	Cert *SecretOrConfigMap `json:"cert,omitempty"`
+
+	// KeySecret is the private key of the client certificate (key defaults to "tls.key")
+	KeySecret *SecretKeySelector `json:"keySecret,omitempty"`
+
+	// ServerName overrides the name used to verify the certificate of the remote endpoint
+	ServerName string `json:"serverName,omitempty"`
+
+	// InsecureSkipVerify disables the verification of the certificate of the remote endpoint
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// SecretOrConfigMap references a key in either a Secret or a ConfigMap
+// Only one of the two can be set
+type SecretOrConfigMap struct {
+	Secret    *SecretKeySelector    `json:"secret,omitempty"`
+	ConfigMap *ConfigMapKeySelector `json:"configMap,omitempty"`
+}
+
+// ConfigMapKeySelector references a key of a ConfigMap
+// The ConfigMap is looked up like the Secrets referenced by a SecretKeySelector, referencing a ConfigMap of another
+// namespace being only allowed if a MimirReferenceGrant in that namespace permits it
+type ConfigMapKeySelector struct {
+	// Name of the ConfigMap
+	Name string `json:"name"`
+
+	// Key of the value in the ConfigMap, a default depending on the field is used if it is empty
+	Key string `json:"key,omitempty"`
+
+	// Namespace of the ConfigMap
+	Namespace string `json:"namespace,omitempty"`
+}
+
+const (
+	// MimirConnectionKind is the kind of the namespaced connection resource
+	MimirConnectionKind = "MimirConnection"
+
+	// ClusterMimirConnectionKind is the kind of the cluster-scoped connection resource
+	ClusterMimirConnectionKind = "ClusterMimirConnection"
+
+	// MimirRulesKind is the kind of the resource synchronizing rules
+	MimirRulesKind = "MimirRules"
+
+	// MimirAlertManagerConfigKind is the kind of the resource synchronizing an AlertManager configuration
+	MimirAlertManagerConfigKind = "MimirAlertManagerConfig"
+)
+
+// ReconcileNowAnnotation requests a full synchronization of a resource when its value changes,
+// content that is unchanged since it was last pushed to Mimir being pushed again
+const ReconcileNowAnnotation = "mimir.randgen.xyz/reconcile-now"
+
+// Deletion policies, deciding what happens to the content of a resource in Mimir when it is deleted
+// or targets another tenant
+const (
+	// DeletionPolicyDelete deletes the content of the resource from Mimir
+	DeletionPolicyDelete = "Delete"
+
+	// DeletionPolicyOrphan leaves the content of the resource in Mimir as is
+	DeletionPolicyOrphan = "Orphan"
+)
+
+// Header is an HTTP header sent with every request to the remote endpoint
+// Its value is either given directly or read from a Secret
+type Header struct {
+	// Name of the header
+	Name string `json:"name"`
+
+	// Value of the header
+	Value string `json:"value,omitempty"`
+
+	// SecretKeyRef references the key of a Secret containing the value of the header, its key must be set
+	// It has precedence over Value
+	SecretKeyRef *SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// ConnectionReference points to a MimirConnection or a ClusterMimirConnection holding the settings
+// used to reach the remote Mimir instance. A MimirConnection must live in the same namespace as
+// the resource referencing it.
+type ConnectionReference struct {
+	// Kind of the referenced connection, defaults to MimirConnection
+	//+kubebuilder:validation:Enum=MimirConnection;ClusterMimirConnection
+	//+kubebuilder:default=MimirConnection
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the referenced connection
+	Name string `json:"name"`
+}
+
+// TargetReference records the tenant a resource was synchronized to, so that its content can be removed from it
+// once the resource targets another tenant
+// Only the identity of the tenant is recorded, the settings used to reach it are read from the resource and the
+// connection it references when it is cleaned up
+type TargetReference struct {
+	// ID of the tenant
+	ID string `json:"id"`
+
+	// URL of the Mimir instance, when it was set inline
+	URL string `json:"url,omitempty"`
+
+	// ConnectionRef referencing the Mimir instance, when it was set through a connection
+	ConnectionRef *ConnectionReference `json:"connectionRef,omitempty"`
+
+	// Fingerprint is a digest of the settings used to reach the tenant, inline credentials and the values of
+	// headers being left out. The credentials of the resource are only sent to the tenant once it targets
+	// another one if they still match it.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// PreviousTarget is a tenant a resource was synchronized to before targeting another one,
+// whose content has yet to be removed
+type PreviousTarget struct {
+	TargetReference `json:",inline"`
+
+	// Target identifies the Mimir instance and the tenant, as "<url>|<tenant>"
+	Target string `json:"target"`
+
+	// ManagedNamespaces lists the Mimir namespaces created in the tenant by a MimirRules
+	ManagedNamespaces []string `json:"managedNamespaces,omitempty"`
 }
@@ -0,0 +1,55 @@
+package v1alpha1
+
+// Types of the conditions reported in the status of MimirRules and MimirAlertManagerConfigs
+// Ready and Synced are "normal-true" conditions, the others are "abnormal-true" conditions: they are only
+// True when something is wrong, following the conventions of kstatus
+const (
+	// ConditionReady is True when the resource is synchronized to Mimir and nothing prevents it from staying so
+	ConditionReady = "Ready"
+
+	// ConditionSynced is True when the last synchronization to Mimir succeeded
+	ConditionSynced = "Synced"
+
+	// ConditionDegraded is True when requests to Mimir are suspended because it keeps failing
+	ConditionDegraded = "Degraded"
+
+	// ConditionAuthFailed is True when Mimir refused the credentials of the operator, or when they couldn't be read
+	ConditionAuthFailed = "AuthFailed"
+
+	// ConditionValidationFailed is True when the content of the resource was rejected as invalid
+	ConditionValidationFailed = "ValidationFailed"
+
+	// ConditionDrifted is True when the state of Mimir was changed outside the operator and was not repaired
+	ConditionDrifted = "Drifted"
+
+	// ConditionConflict is True when rules selected by the resource are synchronized by another resource
+	// targeting the same tenant
+	ConditionConflict = "Conflict"
+
+	// ConditionMigrating is True when the content synchronized to the tenants the resource targeted before
+	// couldn't be removed yet
+	ConditionMigrating = "Migrating"
+
+	// ConditionSuspended is True when the synchronization of the resource to Mimir is suspended
+	ConditionSuspended = "Suspended"
+
+	// ConditionDryRun is True when the changes the resource would make in Mimir are only planned
+	ConditionDryRun = "DryRun"
+)
+
+// Reasons of the conditions reported in the status of MimirRules and MimirAlertManagerConfigs
+const (
+	ReasonSynced        = "Synced"
+	ReasonSyncFailed    = "SyncFailed"
+	ReasonCircuitOpen   = "CircuitOpen"
+	ReasonUnauthorized  = "Unauthorized"
+	ReasonInvalid       = "Invalid"
+	ReasonNoFailure     = "NoFailure"
+	ReasonDriftDetected = "DriftDetected"
+	ReasonDriftRepaired = "DriftRepaired"
+	ReasonConflict      = "NamespaceConflict"
+	ReasonCleanupFailed = "CleanupFailed"
+	ReasonSuspended     = "Suspended"
+	ReasonDryRun        = "DryRun"
+	ReasonOrphaned      = "Orphaned"
+)
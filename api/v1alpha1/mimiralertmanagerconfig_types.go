@@ -12,13 +12,36 @@ type MimirAlertManagerConfigSpec struct {
 	ID string `json:"id"`
 
 	// URL is the URL of the remote Mimir Ruler
-	URL string `json:"url"`
+	// It must be left empty when ConnectionRef is set
+	URL string `json:"url,omitempty"`
 
 	// Authentication configuration if it is required by the remote endpoint
+	// It must be left empty when ConnectionRef is set
 	Auth *Auth `json:"auth,omitempty"`
 
+	// ConnectionRef references a MimirConnection or ClusterMimirConnection holding the URL
+	// and authentication settings of the remote Mimir instance, instead of defining them inline
+	ConnectionRef *ConnectionReference `json:"connectionRef,omitempty"`
+
+	// Headers sent with every request to the remote endpoint, such as routing or gateway headers
+	// They can be used alongside ConnectionRef and override the headers of the connection
+	Headers []Header `json:"headers,omitempty"`
+
 	// Config that should be added to the tenant in the Mimir Alert Manager
 	Config string `json:"config"`
+
+	// DeletionPolicy is what happens to the configuration in Mimir when the resource is deleted or targets another tenant,
+	// it is deleted with Delete and left as is with Orphan. The default policy of the operator is used if it is empty
+	//+kubebuilder:validation:Enum=Delete;Orphan
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// Suspend stops the synchronization of the resource to Mimir, its configuration in Mimir being left as is
+	// until it is resumed, even if the resource is deleted
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun computes the changes the synchronization would make in Mimir and reports them in the status
+	// and events of the resource, without making them
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
 // MimirAlertManagerConfigStatus defines the observed state of MimirAlertManagerConfig
@@ -28,11 +51,46 @@ type MimirAlertManagerConfigStatus struct {
 
 	// Error describes the last synchronization error
 	Error string `json:"error,omitempty"`
+
+	// ObservedGeneration is the generation of the resource last processed by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastSyncTime is the last time the resource was successfully synchronized to Mimir
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// Conditions describe the state of the synchronization of the resource
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastHandledReconcileAt is the value of the reconcile-now annotation when a full synchronization
+	// was last completed
+	LastHandledReconcileAt string `json:"lastHandledReconcileAt,omitempty"`
+
+	// Target identifies the Mimir instance and the tenant the configuration is synchronized to, as "<url>|<tenant>"
+	Target string `json:"target,omitempty"`
+
+	// AppliedTarget is the tenant the configuration was last synchronized to
+	AppliedTarget *TargetReference `json:"appliedTarget,omitempty"`
+
+	// PreviousTargets lists the tenants the MimirAlertManagerConfig targeted before, whose configuration
+	// has yet to be deleted
+	PreviousTargets []PreviousTarget `json:"previousTargets,omitempty"`
+
+	// Plan describes the change the MimirAlertManagerConfig would make in Mimir, it is only set in dry-run mode
+	//+kubebuilder:validation:Enum=Create;Update;Unchanged
+	Plan string `json:"plan,omitempty"`
+
+	// Digest is the digest of the configuration last pushed to Mimir
+	// It is used to detect a configuration changed in Mimir outside the operator
+	Digest string `json:"digest,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Last Sync",type=date,JSONPath=`.status.lastSyncTime`
 
 // MimirAlertManagerConfig is the Schema for the mimiralertmanagerconfigs API
 type MimirAlertManagerConfig struct {
@@ -0,0 +1,129 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// MimirConnectionSpec defines how the operator connects to a remote Mimir instance
+// It is shared by MimirConnection and ClusterMimirConnection
+type MimirConnectionSpec struct {
+	// URL is the URL of the remote Mimir instance
+	URL string `json:"url"`
+
+	// Authentication configuration if it is required by the remote endpoint
+	Auth *Auth `json:"auth,omitempty"`
+
+	// Headers sent with every request to the remote endpoint
+	// Resources referencing the connection can add their own headers, which override these ones
+	Headers []Header `json:"headers,omitempty"`
+
+	// ProxyURL is the URL of the HTTP proxy used to reach the remote endpoint
+	// The proxy configured through the environment of the operator is used if it is not set
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// NoProxy is a comma-separated list of hosts, domains and CIDRs that are reached without going through ProxyURL
+	NoProxy string `json:"noProxy,omitempty"`
+
+	// Retry configures how requests failing with a transient error are retried
+	// Requests are retried when they are throttled (429), on server errors (5xx) and on network errors
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	// Timeouts of the requests sent to the remote endpoint, the defaults of the operator are used for the ones not set
+	Timeouts *Timeouts `json:"timeouts,omitempty"`
+}
+
+// Timeouts configures the timeouts of the requests sent to Mimir
+type Timeouts struct {
+	// Dial is the maximum time spent establishing a TCP connection
+	Dial *metav1.Duration `json:"dial,omitempty"`
+
+	// TLSHandshake is the maximum time spent performing the TLS handshake
+	TLSHandshake *metav1.Duration `json:"tlsHandshake,omitempty"`
+
+	// Request is the maximum duration of a request, each retry having its own timeout
+	Request *metav1.Duration `json:"request,omitempty"`
+}
+
+// RetryPolicy configures how requests to Mimir are retried, using a jittered exponential backoff
+// Delays requested by Mimir through the Retry-After header are honored, up to MaxBackoff
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries of a request, 0 disables retries (defaults to 3)
+	//+kubebuilder:validation:Minimum=0
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+
+	// MinBackoff is the delay before the first retry, doubled on every retry (defaults to 500ms)
+	MinBackoff *metav1.Duration `json:"minBackoff,omitempty"`
+
+	// MaxBackoff is the maximum delay between two retries (defaults to 30s)
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// MimirConnectionStatus defines the observed state of a MimirConnection or ClusterMimirConnection
+type MimirConnectionStatus struct {
+	// Status describes whether the remote Mimir instance is reachable
+	Status string `json:"status,omitempty"`
+
+	// Error describes the last error encountered while reaching the remote Mimir instance
+	Error string `json:"error,omitempty"`
+
+	// LastCheckTime is the last time the operator tried to reach the remote Mimir instance
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+
+// MimirConnection is the Schema for the mimirconnections API
+// It holds the settings used to reach a remote Mimir instance and can be referenced
+// by MimirRules and MimirAlertManagerConfigs in the same namespace
+type MimirConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MimirConnectionSpec   `json:"spec"`
+	Status MimirConnectionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MimirConnectionList contains a list of MimirConnection
+type MimirConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MimirConnection `json:"items"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+
+// ClusterMimirConnection is the Schema for the clustermimirconnections API
+// It is the cluster-scoped counterpart of MimirConnection and can be referenced from any namespace
+// Secrets referenced in its authentication settings are looked up in the cluster resource namespace of the operator
+type ClusterMimirConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MimirConnectionSpec   `json:"spec"`
+	Status MimirConnectionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterMimirConnectionList contains a list of ClusterMimirConnection
+type ClusterMimirConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterMimirConnection `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MimirConnection{}, &MimirConnectionList{})
+	SchemeBuilder.Register(&ClusterMimirConnection{}, &ClusterMimirConnectionList{})
+}
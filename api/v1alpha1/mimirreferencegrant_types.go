@@ -0,0 +1,71 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// Kinds of the resources that can be referenced across namespaces
+const (
+	SecretKind    = "Secret"
+	ConfigMapKind = "ConfigMap"
+)
+
+// MimirReferenceGrantSpec defines which resources of other namespaces may reference resources of the namespace of the grant
+type MimirReferenceGrantSpec struct {
+	// From lists the resources allowed to reference the resources listed in To
+	//+kubebuilder:validation:MinItems=1
+	From []ReferenceGrantFrom `json:"from"`
+
+	// To lists the resources of the namespace of the grant that can be referenced
+	//+kubebuilder:validation:MinItems=1
+	To []ReferenceGrantTo `json:"to"`
+}
+
+// ReferenceGrantFrom describes the resources allowed to reference resources of the namespace of the grant
+type ReferenceGrantFrom struct {
+	// Kind of the referencing resources
+	//+kubebuilder:validation:Enum=MimirRules;MimirAlertManagerConfig;MimirConnection;ClusterMimirConnection
+	Kind string `json:"kind"`
+
+	// Namespace of the referencing resources
+	// It is ignored for ClusterMimirConnections, which are not namespaced
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ReferenceGrantTo describes the resources of the namespace of the grant that can be referenced
+type ReferenceGrantTo struct {
+	// Kind of the referenced resources
+	//+kubebuilder:validation:Enum=Secret;ConfigMap
+	//+kubebuilder:default=Secret
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the referenced resource, every resource of the given kind can be referenced if it is empty
+	Name string `json:"name,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MimirReferenceGrant is the Schema for the mimirreferencegrants API
+// It allows resources of other namespaces to reference Secrets and ConfigMaps of its namespace,
+// cross-namespace references are refused unless a grant in the namespace of the referenced resource permits them
+type MimirReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MimirReferenceGrantSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// MimirReferenceGrantList contains a list of MimirReferenceGrant
+type MimirReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MimirReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MimirReferenceGrant{}, &MimirReferenceGrantList{})
+}
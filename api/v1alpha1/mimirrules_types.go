@@ -28,11 +28,21 @@ type MimirRulesSpec struct {
 	ID string `json:"id"`
 
 	// URL is the URL of the remote Mimir Ruler
-	URL string `json:"url"`
+	// It must be left empty when ConnectionRef is set
+	URL string `json:"url,omitempty"`
 
 	// Authentication configuration if it is required by the remote endpoint
+	// It must be left empty when ConnectionRef is set
 	Auth *Auth `json:"auth,omitempty"`
 
+	// ConnectionRef references a MimirConnection or ClusterMimirConnection holding the URL
+	// and authentication settings of the remote Mimir instance, instead of defining them inline
+	ConnectionRef *ConnectionReference `json:"connectionRef,omitempty"`
+
+	// Headers sent with every request to the remote endpoint, such as routing or gateway headers
+	// They can be used alongside ConnectionRef and override the headers of the connection
+	Headers []Header `json:"headers,omitempty"`
+
 	// Rules that should be added to the tenant in the Mimir Ruler
 	Rules *Rules `json:"rules"`
 
@@ -41,6 +51,22 @@ type MimirRulesSpec struct {
 
 	// ExternalLabels added to the alerts automatically when they are fired
 	ExternalLabels map[string]string `json:"externalLabels,omitempty"`
+
+	// RenderedOutput writes the rules, as they are pushed to Mimir, to ConfigMaps so that they can be reviewed
+	RenderedOutput *RenderedOutput `json:"renderedOutput,omitempty"`
+
+	// DeletionPolicy is what happens to the rules in Mimir when the resource is deleted or targets another tenant,
+	// they are deleted with Delete and left as is with Orphan. The default policy of the operator is used if it is empty
+	//+kubebuilder:validation:Enum=Delete;Orphan
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// Suspend stops the synchronization of the resource to Mimir, its rules in Mimir being left as is
+	// until it is resumed, even if the resource is deleted
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun computes the changes the synchronization would make in Mimir and reports them in the status
+	// and events of the resource, without making them
+	DryRun bool `json:"dryRun,omitempty"`
 }
 
 // Rules that are associated to a tenant and that should be synchronized to the Mimir Ruler
@@ -50,6 +76,18 @@ type Rules struct {
 	Selectors []*metav1.LabelSelector `json:"selectors"`
 }
 
+// RenderedOutput configures the ConfigMaps the rules of a MimirRules are written to, once the overrides and
+// external labels are applied. The ConfigMaps are created in the namespace of the MimirRules and hold one key
+// per Mimir namespace. The rules are split across several ConfigMaps when they don't fit in one.
+type RenderedOutput struct {
+	// Enabled writes the rendered rules to ConfigMaps
+	Enabled bool `json:"enabled"`
+
+	// Name is the prefix of the names of the ConfigMaps, which are suffixed with their index
+	// It defaults to the name of the MimirRules followed by "-rendered"
+	Name string `json:"name,omitempty"`
+}
+
 // Override is a structure containing parameters that can be overridden inside
 // a PrometheusRule. This is useful to override certain alerts within certain
 // alert groups with fine-tuned properties such as the query used to fire the alert.
@@ -62,6 +100,53 @@ type Override struct {
 	For         string            `json:"for,omitempty"`
 }
 
+// States of the synchronization of the rules of a PrometheusRule
+const (
+	// RuleSynced is the state of rules that are synchronized to Mimir
+	RuleSynced = "Synced"
+
+	// RuleRejected is the state of rules that Mimir rejected, or that failed to be pushed
+	RuleRejected = "Rejected"
+
+	// RuleSkipped is the state of rules that were changed in Mimir outside the operator and left as is
+	RuleSkipped = "Skipped"
+
+	// RuleConflict is the state of rules that are also selected by an older MimirRules targeting the same tenant,
+	// which synchronizes them instead
+	RuleConflict = "Conflict"
+)
+
+// RuleSyncResult describes the result of the last synchronization of the rules of a PrometheusRule
+type RuleSyncResult struct {
+	// Namespace of the PrometheusRule
+	Namespace string `json:"namespace"`
+
+	// Name of the PrometheusRule
+	Name string `json:"name"`
+
+	// State of the synchronization of the rules of the PrometheusRule
+	//+kubebuilder:validation:Enum=Synced;Rejected;Skipped;Conflict
+	State string `json:"state"`
+
+	// Error describes why the rules were rejected, or which MimirRules synchronizes them instead
+	Error string `json:"error,omitempty"`
+}
+
+// RulesPlan describes the changes the synchronization of a MimirRules would make in Mimir, computed in dry-run mode
+type RulesPlan struct {
+	// Create lists the Mimir namespaces that would be created
+	Create []string `json:"create,omitempty"`
+
+	// Update lists the Mimir namespaces whose rules would be replaced
+	Update []string `json:"update,omitempty"`
+
+	// Delete lists the Mimir namespaces that would be deleted
+	Delete []string `json:"delete,omitempty"`
+
+	// Unchanged is the number of Mimir namespaces whose rules are already up to date
+	Unchanged int `json:"unchanged,omitempty"`
+}
+
 // MimirRulesStatus defines the status of the synchronization of Rules associated with a MimirRules
 type MimirRulesStatus struct {
 	// Status describes whether the rules are synchronized
@@ -70,6 +155,62 @@ type MimirRulesStatus struct {
 	// Error describes the last synchronization error
 	Error string `json:"error,omitempty"`
 
+	// ObservedGeneration is the generation of the resource last processed by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastSyncTime is the last time the resource was successfully synchronized to Mimir
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// Conditions describe the state of the synchronization of the resource
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Target identifies the Mimir instance and the tenant the rules are synchronized to, as "<url>|<tenant>"
+	// It is used to find the MimirRules sharing a tenant
+	Target string `json:"target,omitempty"`
+
+	// AppliedTarget is the tenant the rules were last synchronized to
+	AppliedTarget *TargetReference `json:"appliedTarget,omitempty"`
+
+	// PreviousTargets lists the tenants the MimirRules targeted before, whose rules have yet to be deleted
+	PreviousTargets []PreviousTarget `json:"previousTargets,omitempty"`
+
+	// Digests holds the digest of the rules last pushed to each Mimir namespace
+	// It is used to push only the namespaces whose rules changed, and to detect rules changed in Mimir outside the operator
+	Digests map[string]string `json:"digests,omitempty"`
+
+	// LastHandledReconcileAt is the value of the reconcile-now annotation when a full synchronization
+	// was last completed
+	LastHandledReconcileAt string `json:"lastHandledReconcileAt,omitempty"`
+
+	// ManagedNamespaces lists the Mimir namespaces created by the MimirRules
+	// Only those namespaces are deleted when they are not wanted anymore, or when the MimirRules is deleted
+	ManagedNamespaces []string `json:"managedNamespaces,omitempty"`
+
+	// ManagedNamespacesRecorded is true once ManagedNamespaces is recorded, even if it is empty
+	// MimirRules last synchronized by older versions of the operator own the namespaces listed in RefRules instead
+	ManagedNamespacesRecorded bool `json:"managedNamespacesRecorded,omitempty"`
+
+	// FullSyncGeneration is the generation of the MimirRules when every Mimir namespace was last synchronized
+	// Until it matches the generation, changes of PrometheusRules are synchronized by a full synchronization
+	FullSyncGeneration int64 `json:"fullSyncGeneration,omitempty"`
+
+	// FailedNamespaces lists the Mimir namespaces that failed to synchronize during the last synchronization
+	// They are synchronized again along with the PrometheusRules that change next
+	FailedNamespaces []string `json:"failedNamespaces,omitempty"`
+
+	// DriftedNamespaces lists the Mimir namespaces whose rules were changed outside the operator
+	// during the last synchronization
+	DriftedNamespaces []string `json:"driftedNamespaces,omitempty"`
+
+	// Plan describes the changes the MimirRules would make in Mimir, it is only set in dry-run mode
+	Plan *RulesPlan `json:"plan,omitempty"`
+
+	// Rules lists the result of the synchronization of each PrometheusRule selected by the MimirRules
+	// A PrometheusRule rejected by Mimir doesn't prevent the others from being synchronized
+	Rules []RuleSyncResult `json:"rules,omitempty"`
+
 	// Store concerned which prometheus rules are used in reference
 	// This allow a better change detection
 	RefRules []string `json:"refRules,omitempty"`
@@ -78,6 +219,8 @@ type MimirRulesStatus struct {
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Last Sync",type=date,JSONPath=`.status.lastSyncTime`
 
 // MimirRules is the Schema for the MimirRules API
 type MimirRules struct {
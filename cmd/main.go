@@ -19,7 +19,9 @@ package main
 import (
 	"crypto/tls"
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
 	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 
@@ -27,10 +29,13 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -38,7 +43,10 @@ import (
 
 	mimirrandgenxyzv1alpha1 "github.com/AmiditeX/mimir-operator/api/v1alpha1"
 	amCtrl "github.com/AmiditeX/mimir-operator/internal/controller/mimiralertmanagerconfig"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	connCtrl "github.com/AmiditeX/mimir-operator/internal/controller/mimirconnection"
 	mimirCtrl "github.com/AmiditeX/mimir-operator/internal/controller/mimirrules"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -61,6 +69,15 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var clusterResourceNamespace string
+	var clientTimeouts mimirapi.Timeouts
+	var clientIdleTimeout time.Duration
+	var endpointLimits mimirapi.EndpointLimits
+	var pushConcurrency int
+	var resyncInterval time.Duration
+	var driftRepair bool
+	var deletionPolicy string
+	var dryRun bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -70,6 +87,39 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&clusterResourceNamespace, "cluster-resource-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace in which the Secrets referenced by ClusterMimirConnections are looked up. "+
+			"Defaults to the namespace the operator is running in.")
+	flag.DurationVar(&clientTimeouts.Dial, "mimir-dial-timeout", mimirapi.DefaultTimeouts.Dial,
+		"The maximum time spent establishing a connection to Mimir, unless overridden by a connection.")
+	flag.DurationVar(&clientTimeouts.TLSHandshake, "mimir-tls-handshake-timeout", mimirapi.DefaultTimeouts.TLSHandshake,
+		"The maximum time spent performing the TLS handshake with Mimir, unless overridden by a connection.")
+	flag.DurationVar(&clientTimeouts.Request, "mimir-request-timeout", mimirapi.DefaultTimeouts.Request,
+		"The maximum duration of a request to Mimir, unless overridden by a connection.")
+	flag.DurationVar(&clientIdleTimeout, "mimir-client-idle-timeout", 10*time.Minute,
+		"How long the HTTP client of a Mimir endpoint is kept once it is no longer used. 0 keeps it forever.")
+	flag.Float64Var(&endpointLimits.RateLimit, "mimir-rate-limit", 20,
+		"The maximum number of requests per second sent to a Mimir endpoint. 0 disables rate limiting.")
+	flag.IntVar(&endpointLimits.RateBurst, "mimir-rate-burst", 40,
+		"The number of requests that can be sent at once to a Mimir endpoint above the rate limit.")
+	flag.IntVar(&endpointLimits.BreakerThreshold, "mimir-circuit-breaker-threshold", 5,
+		"The number of consecutive failed requests after which requests to a Mimir endpoint are suspended. "+
+			"0 disables the circuit breaker.")
+	flag.DurationVar(&endpointLimits.BreakerCooldown, "mimir-circuit-breaker-cooldown", 30*time.Second,
+		"How long requests to a failing Mimir endpoint are suspended before checking if it recovered.")
+	flag.IntVar(&pushConcurrency, "mimir-push-concurrency", 8,
+		"The number of rule groups pushed, or rule namespaces deleted, at once when synchronizing a MimirRules.")
+	flag.DurationVar(&resyncInterval, "resync-interval", 10*time.Minute,
+		"How often resources are synchronized again to find changes made in Mimir outside the operator, "+
+			"with up to 10% of jitter. 0 disables the periodic resync.")
+	flag.BoolVar(&driftRepair, "drift-repair", true,
+		"If set, changes made in Mimir outside the operator are overwritten. Otherwise they are only reported.")
+	flag.StringVar(&deletionPolicy, "default-deletion-policy", mimirrandgenxyzv1alpha1.DeletionPolicyDelete,
+		"What happens to the content of resources in Mimir when they are deleted or target another tenant, "+
+			"unless they set a deletionPolicy: Delete or Orphan.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"If set, the changes resources would make in Mimir are only reported in their status and events, "+
+			"nothing being written to Mimir.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -78,6 +128,11 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if deletionPolicy != mimirrandgenxyzv1alpha1.DeletionPolicyDelete && deletionPolicy != mimirrandgenxyzv1alpha1.DeletionPolicyOrphan {
+		setupLog.Error(fmt.Errorf("unsupported deletion policy '%s'", deletionPolicy), "invalid flag", "flag", "default-deletion-policy")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -101,6 +156,20 @@ func main() {
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Client: client.Options{
+			Cache: &client.CacheOptions{
+				// Secrets are never cached by the manager, the content of the referenced ones is read directly
+				// from the API server. Only their metadata is cached, by the reference cache
+				DisableFor: []client.Object{&corev1.Secret{}},
+			},
+		},
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				// The only ConfigMaps cached are those holding the rendered rules of MimirRules,
+				// the referenced ones are read directly from the API server
+				&corev1.ConfigMap{}: {Label: utils.LabelExists(mimirCtrl.RenderedOutputLabel)},
+			},
+		},
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -127,20 +196,71 @@ func main() {
 		os.Exit(1)
 	}
 
+	// HTTP clients, rate limiters and circuit breakers are shared between the reconciliations targeting the same Mimir endpoint
+	pool := mimirapi.NewPool(clientTimeouts, clientIdleTimeout, endpointLimits)
+	pool.Concurrency = pushConcurrency
+	if err := mgr.Add(pool); err != nil {
+		setupLog.Error(err, "unable to set up the Mimir client pool")
+		os.Exit(1)
+	}
+
+	// The metadata of the Secrets and ConfigMaps is cached, to synchronize the resources referencing them when they change
+	references, err := utils.NewReferenceCache(mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to set up the reference cache")
+		os.Exit(1)
+	}
+
+	connections := &connCtrl.Resolver{
+		Client:                   mgr.GetClient(),
+		APIReader:                mgr.GetAPIReader(),
+		Pool:                     pool,
+		References:               references,
+		ClusterResourceNamespace: clusterResourceNamespace,
+	}
+
 	if err = (&mimirCtrl.MimirRulesReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		Connections:           connections,
+		Recorder:              mgr.GetEventRecorderFor("mimirrules-controller"),
+		ResyncInterval:        resyncInterval,
+		DriftRepair:           driftRepair,
+		DefaultDeletionPolicy: deletionPolicy,
+		DryRun:                dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MimirRules")
 		os.Exit(1)
 	}
 	if err = (&amCtrl.MimirAlertManagerConfigReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		Connections:           connections,
+		Recorder:              mgr.GetEventRecorderFor("mimiralertmanagerconfig-controller"),
+		ResyncInterval:        resyncInterval,
+		DriftRepair:           driftRepair,
+		DefaultDeletionPolicy: deletionPolicy,
+		DryRun:                dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MimirAlertManagerConfig")
 		os.Exit(1)
 	}
+	if err = (&connCtrl.MimirConnectionReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MimirConnection")
+		os.Exit(1)
+	}
+	if err = (&connCtrl.ClusterMimirConnectionReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Connections: connections,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterMimirConnection")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,41 @@
+package mimiralertmanagerconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	corev1 "k8s.io/api/core/v1"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/metrics"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+// configDigest returns the digest of an Alertmanager configuration
+func configDigest(config string) string {
+	sum := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(sum[:])
+}
+
+// reportDrift records whether the configuration of a MimirAlertManagerConfig was changed in Mimir outside the operator
+// in its status, metrics and events
+// repaired is whether the drift is going to be overwritten by the synchronization
+func (r *MimirAlertManagerConfigReconciler) reportDrift(amc *domain.MimirAlertManagerConfig, drifted, repaired bool) {
+	var description []string
+	if drifted {
+		description = []string{"Alertmanager configuration"}
+	}
+
+	utils.SetDriftCondition(&amc.Status.Conditions, amc.Generation, description, repaired)
+	metrics.SetDrift(domain.MimirAlertManagerConfigKind, amc.Namespace, amc.Name, amc.Spec.ID, len(description))
+
+	if !drifted {
+		return
+	}
+
+	if repaired {
+		r.Recorder.Event(amc, corev1.EventTypeNormal, domain.ReasonDriftRepaired, "Repaired the Alertmanager configuration changed in Mimir outside the operator")
+	} else {
+		r.Recorder.Event(amc, corev1.EventTypeWarning, domain.ReasonDriftDetected, "The Alertmanager configuration was changed in Mimir outside the operator")
+	}
+}
@@ -0,0 +1,57 @@
+package mimiralertmanagerconfig
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestDriftRepair(t *testing.T) {
+	tests := map[string]struct {
+		driftRepair  bool
+		reconcileNow bool
+		repaired     bool
+	}{
+		"repaired":             {driftRepair: true, repaired: true},
+		"detected":             {},
+		"repaired on request":  {reconcileNow: true, repaired: true},
+		"repaired with repair": {driftRepair: true, reconcileNow: true, repaired: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			am := newFakeAlertmanager(t)
+			amc := newAlertManagerConfig("config", am.URL, "route: a")
+			r := newTestReconciler(t, amc)
+			r.DriftRepair = test.driftRepair
+
+			runReconcile(t, r, "config")
+
+			am.setConfig("route: changed")
+			if test.reconcileNow {
+				update(t, r, amc, func() { amc.Annotations = map[string]string{domain.ReconcileNowAnnotation: "now"} })
+			}
+			amc = runReconcile(t, r, "config")
+
+			expected := "route: changed"
+			if test.repaired {
+				expected = "route: a"
+			}
+			if config, _ := am.currentConfig(); config != expected {
+				t.Errorf("expected the configuration of Mimir to be %q, got %q", expected, config)
+			}
+			reason := domain.ReasonDriftDetected
+			if test.repaired {
+				reason = domain.ReasonDriftRepaired
+			}
+			if condition := meta.FindStatusCondition(amc.Status.Conditions, domain.ConditionDrifted); condition == nil || condition.Reason != reason {
+				t.Errorf("expected the drift to be reported as %s, got %+v", reason, condition)
+			}
+			if test.reconcileNow && amc.Status.LastHandledReconcileAt != "now" {
+				t.Errorf("expected the reconcile-now request to be handled, got %q", amc.Status.LastHandledReconcileAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package mimiralertmanagerconfig
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// Changes a MimirAlertManagerConfig would make in Mimir, planned in dry-run mode
+const (
+	planCreate    = "Create"
+	planUpdate    = "Update"
+	planUnchanged = "Unchanged"
+)
+
+// dryRun returns whether the changes a MimirAlertManagerConfig would make in Mimir are only planned
+func (r *MimirAlertManagerConfigReconciler) dryRun(amc *domain.MimirAlertManagerConfig) bool {
+	return r.DryRun || amc.Spec.DryRun
+}
+
+// planConfig computes the change the synchronization of a MimirAlertManagerConfig would make in Mimir, without making it
+// live is the configuration of the tenant in Mimir, and exists whether the tenant has one
+func (r *MimirAlertManagerConfigReconciler) planConfig(amc *domain.MimirAlertManagerConfig, live string, exists bool) {
+	plan := planUnchanged
+	switch {
+	case !exists:
+		plan = planCreate
+	case configDigest(live) != configDigest(amc.Spec.Config):
+		plan = planUpdate
+	}
+
+	// The plan is computed on every resync, it is only reported again when it changes
+	if plan != amc.Status.Plan {
+		r.Recorder.Event(amc, corev1.EventTypeNormal, domain.ReasonDryRun, describePlan(plan))
+	}
+	amc.Status.Plan = plan
+}
+
+// describePlan returns a description of a planned change
+func describePlan(plan string) string {
+	switch plan {
+	case planCreate:
+		return "Dry run: would create the Alertmanager configuration"
+	case planUpdate:
+		return "Dry run: would replace the Alertmanager configuration"
+	default:
+		return "Dry run: the Alertmanager configuration is up to date"
+	}
+}
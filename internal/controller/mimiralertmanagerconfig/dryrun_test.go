@@ -0,0 +1,102 @@
+package mimiralertmanagerconfig
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// dryRunEvents returns the number of DryRun events recorded since it was last called
+func dryRunEvents(recorder *record.FakeRecorder) int {
+	count := 0
+	for {
+		select {
+		case event := <-recorder.Events:
+			if strings.Contains(event, domain.ReasonDryRun) {
+				count++
+			}
+		default:
+			return count
+		}
+	}
+}
+
+func TestDryRunPlansChanges(t *testing.T) {
+	am := newFakeAlertmanager(t)
+	amc := newAlertManagerConfig("config", am.URL, "route: a")
+	amc.Spec.DryRun = true
+	r := newTestReconciler(t, amc)
+	recorder := record.NewFakeRecorder(100)
+	r.Recorder = recorder
+
+	// The tenant has no configuration yet
+	amc = runReconcile(t, r, "config")
+
+	if requests := am.takeRequests(); !slices.Equal(requests, []string{http.MethodGet}) {
+		t.Errorf("expected the configuration to only be read, got %v", requests)
+	}
+	if amc.Status.Status != "DryRun" || amc.Status.Plan != planCreate || !meta.IsStatusConditionTrue(amc.Status.Conditions, domain.ConditionDryRun) {
+		t.Errorf("expected the creation to be planned, got %s, %q and %+v", amc.Status.Status, amc.Status.Plan, amc.Status.Conditions)
+	}
+
+	// The plan is only reported again when it changes
+	runReconcile(t, r, "config")
+	if events := dryRunEvents(recorder); events != 1 {
+		t.Errorf("expected the plan to be reported once, got %d events", events)
+	}
+
+	// The tenant has another configuration
+	am.setConfig("route: b")
+	amc = runReconcile(t, r, "config")
+
+	if amc.Status.Plan != planUpdate || dryRunEvents(recorder) != 1 {
+		t.Errorf("expected the replacement to be planned and reported, got %q", amc.Status.Plan)
+	}
+	if config, _ := am.currentConfig(); config != "route: b" {
+		t.Errorf("expected Mimir to be left as is, got %q", config)
+	}
+
+	// The plan is dropped once the change is synchronized
+	update(t, r, amc, func() { amc.Spec.DryRun = false })
+	amc = runReconcile(t, r, "config")
+
+	if config, _ := am.currentConfig(); config != "route: a" {
+		t.Errorf("expected the planned change to be made, got %q", config)
+	}
+	if amc.Status.Status != "Synced" || amc.Status.Plan != "" {
+		t.Errorf("expected the configuration to be synced without a plan, got %s and %q", amc.Status.Status, amc.Status.Plan)
+	}
+}
+
+func TestDryRunLeavesConfigOnDeletion(t *testing.T) {
+	am := newFakeAlertmanager(t)
+	amc := newAlertManagerConfig("config", am.URL, "route: a")
+	r := newTestReconciler(t, amc)
+
+	runReconcile(t, r, "config")
+
+	// The operator-wide dry-run mode applies to every MimirAlertManagerConfig
+	r.DryRun = true
+	if err := r.Delete(context.Background(), amc); err != nil {
+		t.Fatalf("failed to delete the MimirAlertManagerConfig: %v", err)
+	}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(amc)}); err != nil {
+		t.Fatalf("failed to reconcile the deletion: %v", err)
+	}
+
+	if _, exists := am.currentConfig(); !exists {
+		t.Errorf("expected the configuration to be left in Mimir")
+	}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(amc), amc); err == nil {
+		t.Errorf("expected the MimirAlertManagerConfig to be released")
+	}
+}
@@ -0,0 +1,103 @@
+package mimiralertmanagerconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirconnection"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+// trackTarget records the tenant a MimirAlertManagerConfig is synchronized to, target identifying it
+// When the MimirAlertManagerConfig targets another tenant, the previous one is kept aside for its configuration
+// to be deleted once it is synchronized to the new one
+func (r *MimirAlertManagerConfigReconciler) trackTarget(ctx context.Context, amc *domain.MimirAlertManagerConfig, target string) {
+	if amc.Status.Target != "" && mimirapi.NormalizeTarget(amc.Status.Target) != target && amc.Status.AppliedTarget != nil {
+		amc.Status.PreviousTargets = append(amc.Status.PreviousTargets, domain.PreviousTarget{
+			TargetReference: *amc.Status.AppliedTarget,
+			Target:          amc.Status.Target,
+		})
+		r.Recorder.Eventf(amc, corev1.EventTypeNormal, "TargetChanged", "Moving the Alertmanager configuration from %s to %s", amc.Status.Target, target)
+
+		// Nothing was pushed to the new tenant yet
+		amc.Status.Digest = ""
+	}
+
+	// Moving back to a previous tenant, its configuration is overwritten instead of being deleted
+	amc.Status.PreviousTargets = slices.DeleteFunc(amc.Status.PreviousTargets, func(previous domain.PreviousTarget) bool {
+		return mimirapi.NormalizeTarget(previous.Target) == target
+	})
+
+	amc.Status.Target = target
+	// The fingerprint of the settings used to reach the tenant is recorded along with it, without their credentials
+	reference, err := r.Connections.Reference(ctx, r.target(amc))
+	if err != nil {
+		reference = r.target(amc).Reference()
+	}
+	amc.Status.AppliedTarget = reference
+}
+
+// cleanupPreviousTargets deletes the Alertmanager configuration of the tenants a MimirAlertManagerConfig targeted before,
+// or leaves it as is if its configuration is orphaned
+// Tenants that can't be cleaned up are kept to be tried again on the next synchronization, their errors
+// being returned and reported by the Migrating condition
+func (r *MimirAlertManagerConfigReconciler) cleanupPreviousTargets(ctx context.Context, amc *domain.MimirAlertManagerConfig) error {
+	if r.deletionPolicy(amc) == domain.DeletionPolicyOrphan {
+		for _, previous := range amc.Status.PreviousTargets {
+			r.Recorder.Eventf(amc, corev1.EventTypeNormal, "ConfigOrphaned", "Left the Alertmanager configuration of %s in Mimir", previous.Target)
+		}
+		amc.Status.PreviousTargets = nil
+	}
+
+	var errs []error
+
+	remaining := amc.Status.PreviousTargets[:0]
+	for _, previous := range amc.Status.PreviousTargets {
+		err := r.cleanupPreviousTarget(ctx, amc, previous)
+		// The content of a tenant that can't be reached anymore is left as is, rather than trying again forever
+		if errors.Is(err, mimirconnection.ErrPreviousTargetUnreachable) {
+			r.Recorder.Eventf(amc, corev1.EventTypeWarning, "ConfigOrphaned", "Left the Alertmanager configuration of %s in Mimir: %v", previous.Target, err)
+			continue
+		}
+		if err != nil {
+			remaining = append(remaining, previous)
+			errs = append(errs, fmt.Errorf("failed to delete the Alertmanager configuration of %s: %w", previous.Target, err))
+			continue
+		}
+		r.Recorder.Eventf(amc, corev1.EventTypeNormal, "ConfigDeleted", "Deleted the Alertmanager configuration of %s, which is not targeted anymore", previous.Target)
+	}
+	if len(remaining) == 0 {
+		remaining = nil
+	}
+	amc.Status.PreviousTargets = remaining
+
+	err := errors.Join(errs...)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to clean up the tenants previously targeted by MimirAlertManagerConfig")
+	}
+	utils.SetMigratingCondition(&amc.Status.Conditions, amc.Generation, err)
+
+	return err
+}
+
+// cleanupPreviousTarget deletes the Alertmanager configuration of a tenant a MimirAlertManagerConfig targeted before
+func (r *MimirAlertManagerConfigReconciler) cleanupPreviousTarget(ctx context.Context, amc *domain.MimirAlertManagerConfig, previous domain.PreviousTarget) error {
+	mc, err := r.Connections.NewPreviousClient(ctx, r.target(amc), previous)
+	if err != nil {
+		return err
+	}
+
+	// A configuration already deleted from Mimir is fine
+	if err := mc.DeleteAlermanagerConfig(ctx); err != nil && !errors.Is(err, mimirapi.ErrResourceNotFound) {
+		return err
+	}
+
+	return nil
+}
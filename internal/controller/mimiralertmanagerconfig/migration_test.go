@@ -0,0 +1,81 @@
+package mimiralertmanagerconfig
+
+import (
+	"testing"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestMigration(t *testing.T) {
+	tests := map[string]struct {
+		policy string
+		kept   bool
+	}{
+		"delete": {policy: domain.DeletionPolicyDelete},
+		"orphan": {policy: domain.DeletionPolicyOrphan, kept: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			previous, next := newFakeAlertmanager(t), newFakeAlertmanager(t)
+			amc := newAlertManagerConfig("config", previous.URL, "route: a")
+			amc.Spec.DeletionPolicy = test.policy
+			r := newTestReconciler(t, amc)
+
+			runReconcile(t, r, "config")
+
+			update(t, r, amc, func() { amc.Spec.URL = next.URL })
+			amc = runReconcile(t, r, "config")
+
+			if amc.Status.Status != "Synced" {
+				t.Fatalf("expected the configuration to be synced, got %s: %s", amc.Status.Status, amc.Status.Error)
+			}
+			if config, _ := next.currentConfig(); config != "route: a" {
+				t.Errorf("expected the configuration to be pushed to the new tenant, got %q", config)
+			}
+			if _, exists := previous.currentConfig(); exists != test.kept {
+				t.Errorf("expected the configuration to be kept in the previous tenant: %v, got %v", test.kept, exists)
+			}
+			if len(amc.Status.PreviousTargets) != 0 {
+				t.Errorf("expected the previous tenant to be forgotten, got %+v", amc.Status.PreviousTargets)
+			}
+			if amc.Status.Target != next.URL+"|tenant" {
+				t.Errorf("expected the new tenant to be targeted, got %s", amc.Status.Target)
+			}
+		})
+	}
+}
+
+func TestMigrationToFailingTenant(t *testing.T) {
+	previous, next := newFakeAlertmanager(t), newFakeAlertmanager(t)
+	amc := newAlertManagerConfig("config", previous.URL, "route: a")
+	r := newTestReconciler(t, amc)
+
+	runReconcile(t, r, "config")
+
+	// The configuration is kept in the previous tenant until it is synchronized to the new one
+	next.setFailing(true)
+	update(t, r, amc, func() { amc.Spec.URL = next.URL })
+	amc = runReconcile(t, r, "config")
+
+	if amc.Status.Status == "Synced" {
+		t.Fatalf("expected the synchronization to the failing tenant to fail")
+	}
+	if _, exists := previous.currentConfig(); !exists {
+		t.Errorf("expected the configuration to be kept in the previous tenant")
+	}
+	if len(amc.Status.PreviousTargets) != 1 || amc.Status.PreviousTargets[0].Target != previous.URL+"|tenant" {
+		t.Fatalf("expected the previous tenant to be kept, got %+v", amc.Status.PreviousTargets)
+	}
+
+	// Once the new tenant recovers, the previous one is cleaned up
+	next.setFailing(false)
+	amc = runReconcile(t, r, "config")
+
+	if _, exists := previous.currentConfig(); exists {
+		t.Errorf("expected the configuration to be deleted from the previous tenant")
+	}
+	if len(amc.Status.PreviousTargets) != 0 {
+		t.Errorf("expected the previous tenant to be forgotten, got %+v", amc.Status.PreviousTargets)
+	}
+}
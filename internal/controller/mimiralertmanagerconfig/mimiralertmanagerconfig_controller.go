@@ -2,17 +2,27 @@ package mimiralertmanagerconfig
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
 	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirconnection"
+	"github.com/AmiditeX/mimir-operator/internal/metrics"
 	"github.com/AmiditeX/mimir-operator/internal/utils"
 )
 
@@ -24,12 +34,27 @@ const (
 // MimirAlertManagerConfigReconciler reconciles a MimirAlertManagerConfig object
 type MimirAlertManagerConfigReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme      *runtime.Scheme
+	Connections *mimirconnection.Resolver
+	Recorder    record.EventRecorder
+
+	// ResyncInterval is how often a MimirAlertManagerConfig is synchronized again to find changes made in Mimir, 0 disables it
+	ResyncInterval time.Duration
+
+	// DriftRepair is whether changes made in Mimir outside the operator are overwritten
+	DriftRepair bool
+
+	// DefaultDeletionPolicy is the deletion policy of the MimirAlertManagerConfigs that don't set one
+	DefaultDeletionPolicy string
+
+	// DryRun is whether the changes of every MimirAlertManagerConfig are only planned, nothing being written to Mimir
+	DryRun bool
 }
 
 //+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimiralertmanagerconfigs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimiralertmanagerconfigs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimiralertmanagerconfigs/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -46,8 +71,20 @@ func (r *MimirAlertManagerConfigReconciler) Reconcile(ctx context.Context, req c
 
 	log.FromContext(ctx).Info("Running reconcile on MimirAlertManagerConfig")
 
+	// Nothing is sent to Mimir while the MimirAlertManagerConfig is suspended, not even when it is deleted
+	if amc.Spec.Suspend {
+		if !amc.DeletionTimestamp.IsZero() {
+			return ctrl.Result{}, r.releaseSuspended(ctx, amc)
+		}
+		return ctrl.Result{}, r.setSuspended(ctx, amc)
+	}
+
+	// Mimir doesn't need to be reached to release a MimirAlertManagerConfig whose configuration is orphaned,
+	// or left as is in dry-run mode
+	orphaning := !amc.DeletionTimestamp.IsZero() && (r.deletionPolicy(amc) == domain.DeletionPolicyOrphan || r.dryRun(amc))
+
 	mc, err := r.createMimirClient(ctx, amc)
-	if err != nil {
+	if err != nil && !orphaning {
 		// Update status with an error if we can't create a client for Mimir Api
 		return ctrl.Result{}, r.setStatus(ctx, amc, err)
 	}
@@ -63,93 +100,290 @@ func (r *MimirAlertManagerConfigReconciler) Reconcile(ctx context.Context, req c
 				return ctrl.Result{}, err
 			}
 		}
-	} else {
+	}
+
+	// The target is recorded once the finalizer is added, as the update of the MimirAlertManagerConfig resets its status
+	if mc != nil {
+		r.trackTarget(ctx, amc, mc.Target())
+	}
+
+	if !amc.ObjectMeta.DeletionTimestamp.IsZero() {
 		// The object is being deleted
 		if controllerutil.ContainsFinalizer(amc, alertManagerFinalizer) {
-			if err := r.handleDeletion(ctx, mc); err != nil {
+			if err := r.handleDeletion(ctx, amc, mc); err != nil {
 				// Status is set only on failure to delete (the status is going to be deleted anyway if it succeeds)
-				return ctrl.Result{}, r.setStatus(ctx, amc, err)
+				return mimirconnection.RequeueOnDegraded(err), r.setStatus(ctx, amc, err)
 			}
 
+			metrics.Forget(domain.MimirAlertManagerConfigKind, amc.Namespace, amc.Name)
+
 			// Remove our finalizer from the list and update it
 			controllerutil.RemoveFinalizer(amc, alertManagerFinalizer)
 			return ctrl.Result{}, r.Update(ctx, amc)
 		}
 	}
 
-	return ctrl.Result{}, r.handleCreationAndChanges(ctx, amc, mc)
+	return r.handleCreationAndChanges(ctx, amc, mc)
 }
 
 func (r *MimirAlertManagerConfigReconciler) createMimirClient(ctx context.Context, amc *domain.MimirAlertManagerConfig) (*mimirapi.MimirClient, error) {
-	auth, err := utils.ExtractAuth(ctx, r.Client, amc.Spec.Auth, amc.ObjectMeta.Namespace)
-	if err != nil {
-		return nil, fmt.Errorf("failed to extract authentication settings: %w", err)
-	}
+	return r.Connections.NewMimirClient(ctx, r.target(amc))
+}
 
-	c, err := mimirapi.New(mimirapi.Config{
-		User:      auth.Username,
-		Key:       auth.Key,
-		AuthToken: auth.Token,
-		Address:   amc.Spec.URL,
-		ID:        amc.Spec.ID,
-	})
+// target returns the tenant a MimirAlertManagerConfig is synchronized to
+func (r *MimirAlertManagerConfigReconciler) target(amc *domain.MimirAlertManagerConfig) mimirconnection.Target {
+	return mimirconnection.Target{
+		Kind:          domain.MimirAlertManagerConfigKind,
+		ID:            amc.Spec.ID,
+		URL:           amc.Spec.URL,
+		Auth:          amc.Spec.Auth,
+		ConnectionRef: amc.Spec.ConnectionRef,
+		Headers:       amc.Spec.Headers,
+		Namespace:     amc.Namespace,
+	}
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create mimir client: %w", err)
+// deletionPolicy returns whether the configuration of a MimirAlertManagerConfig is deleted from Mimir or orphaned
+func (r *MimirAlertManagerConfigReconciler) deletionPolicy(amc *domain.MimirAlertManagerConfig) string {
+	if amc.Spec.DeletionPolicy != "" {
+		return amc.Spec.DeletionPolicy
 	}
 
-	return c, nil
+	return r.DefaultDeletionPolicy
 }
 
 // handleCreationAndChanges handles reconciliation of Alert Manager Config for events that are not a deletion
 // This means that this function will be called for any modification in an Alert Manager Config or for
 // any creation of a new Alert Manager Config in the API. It is also called periodically for scheduled
 // reconciliation and at the startup of the controller.
-func (r *MimirAlertManagerConfigReconciler) handleCreationAndChanges(ctx context.Context, amc *domain.MimirAlertManagerConfig, mc *mimirapi.MimirClient) error {
-	reconciliationError := r.reconcileAMConfig(ctx, amc.Spec.Config, mc)
+func (r *MimirAlertManagerConfigReconciler) handleCreationAndChanges(ctx context.Context, amc *domain.MimirAlertManagerConfig, mc *mimirapi.MimirClient) (ctrl.Result, error) {
+	reconciliationError := r.reconcileAMConfig(ctx, amc, mc)
+
+	// The configuration is deleted from the tenants targeted before only once it is fully synchronized to the new one,
+	// so that switching to a failing tenant never leaves the configuration missing from both. Until then the previous
+	// tenants are kept in the status. Cleanup failures are reported by the Migrating condition without
+	// failing the synchronization
+	if reconciliationError == nil && !r.dryRun(amc) {
+		_ = r.cleanupPreviousTargets(ctx, amc)
+	}
+
 	if err := r.setStatus(ctx, amc, reconciliationError); err != nil {
-		return err
+		return ctrl.Result{}, err
 	}
 
-	log.FromContext(ctx).Info("MimirAlertManagerConfig correctly synchronized")
-	return nil
+	// Mimir refuses requests while it is degraded, try again once the circuit breaker lets requests through
+	if result := mimirconnection.RequeueOnDegraded(reconciliationError); !result.IsZero() {
+		log.FromContext(ctx).Info("MimirAlertManagerConfig synchronization postponed until Mimir recovers", "requeueAfter", result.RequeueAfter)
+		return result, nil
+	}
+
+	if reconciliationError == nil {
+		log.FromContext(ctx).Info("MimirAlertManagerConfig correctly synchronized")
+	}
+
+	// Synchronize again later to find and repair changes made in Mimir outside the operator
+	return mimirconnection.RequeueForResync(r.ResyncInterval), nil
 }
 
 // handleDeletion handles cleaning up after the deletion of a MimirAlertManagerConfig
-func (r *MimirAlertManagerConfigReconciler) handleDeletion(ctx context.Context, mc *mimirapi.MimirClient) error {
+func (r *MimirAlertManagerConfigReconciler) handleDeletion(ctx context.Context, amc *domain.MimirAlertManagerConfig, mc *mimirapi.MimirClient) error {
 	log.FromContext(ctx).Info("Running reconciliation on deletion of a MimirAlertManagerConfig")
 
-	return mc.DeleteAlermanagerConfig(ctx)
+	if r.dryRun(amc) {
+		r.Recorder.Event(amc, corev1.EventTypeNormal, domain.ReasonDryRun, "Dry run: would delete the Alertmanager configuration")
+		return nil
+	}
+
+	if r.deletionPolicy(amc) == domain.DeletionPolicyOrphan {
+		r.Recorder.Eventf(amc, corev1.EventTypeNormal, "ConfigOrphaned", "Left the Alertmanager configuration of tenant %s in Mimir", amc.Spec.ID)
+		return r.cleanupPreviousTargets(ctx, amc)
+	}
+
+	if err := mc.DeleteAlermanagerConfig(ctx); err != nil {
+		return err
+	}
+
+	r.Recorder.Eventf(amc, corev1.EventTypeNormal, "ConfigDeleted", "Deleted the Alertmanager configuration of tenant %s from Mimir", amc.Spec.ID)
+
+	return r.cleanupPreviousTargets(ctx, amc)
 }
 
 // reconcileAMConfig ensures Mimir correctly load the alert manager config
-func (r *MimirAlertManagerConfigReconciler) reconcileAMConfig(ctx context.Context, config string, mc *mimirapi.MimirClient) error {
+// The configuration in Mimir is compared with the one last pushed to find changes made outside the operator
+func (r *MimirAlertManagerConfigReconciler) reconcileAMConfig(ctx context.Context, amc *domain.MimirAlertManagerConfig, mc *mimirapi.MimirClient) error {
 	log.FromContext(ctx).Info("Running reconciliation of the Alert Manager Config")
 
-	return mc.CreateAlertmanagerConfig(ctx, config, nil)
+	// Mimir answers with a 404 when the tenant has no configuration
+	live, _, err := mc.GetAlertmanagerConfig(ctx)
+	if err != nil && !errors.Is(err, mimirapi.ErrResourceNotFound) {
+		return err
+	}
+
+	if r.dryRun(amc) {
+		r.planConfig(amc, live, err == nil)
+		return nil
+	}
+
+	// A full synchronization is requested by changing the reconcile-now annotation, it also repairs the drift
+	reconcileNow := amc.Annotations[domain.ReconcileNowAnnotation]
+	fullSync := reconcileNow != "" && reconcileNow != amc.Status.LastHandledReconcileAt
+
+	desired := configDigest(amc.Spec.Config)
+	drifted := amc.Status.Digest != "" && configDigest(live) != amc.Status.Digest
+	r.reportDrift(amc, drifted, r.DriftRepair || fullSync)
+
+	// The configuration last pushed is still the one of Mimir and didn't change in the cluster, there's nothing to push
+	if !drifted && !fullSync && desired == amc.Status.Digest {
+		return nil
+	}
+
+	// Without drift repair, a configuration changed in Mimir is only overwritten once it changes in the cluster too
+	if drifted && !r.DriftRepair && !fullSync && desired == amc.Status.Digest {
+		return nil
+	}
+
+	if err := mc.CreateAlertmanagerConfig(ctx, amc.Spec.Config, nil); err != nil {
+		return err
+	}
+	amc.Status.Digest = desired
+	if fullSync {
+		amc.Status.LastHandledReconcileAt = reconcileNow
+	}
+
+	r.Recorder.Eventf(amc, corev1.EventTypeNormal, "ConfigPushed", "Pushed the Alertmanager configuration of tenant %s to Mimir", amc.Spec.ID)
+	return nil
 }
 
 // setStatus updates the status of MimirAlertManagerConfig after reconciliation
 // If err is not nil, the error field is populated with the error and the status is set as "Failed"
-// Otherwise, status is set as "Synced"
+// If the error was caused by an open circuit breaker on the Mimir endpoint, the status is set as "Degraded" instead
+// Otherwise, status is set as "Synced", or as "DryRun" if the change was only planned
+// The conditions of the status are updated accordingly, and a Warning event is emitted on failure
 func (r *MimirAlertManagerConfigReconciler) setStatus(ctx context.Context, amc *domain.MimirAlertManagerConfig, err error) error {
-	if err != nil {
+	if _, degraded := mimirapi.CircuitOpenDelay(err); degraded {
+		amc.Status.Status = "Degraded"
+		amc.Status.Error = err.Error()
+	} else if err != nil {
 		amc.Status.Status = "Failed"
 		amc.Status.Error = err.Error()
 
 		// Also log the error in the controller for clarity
 		log.FromContext(ctx).Error(err, "Failed to reconcile MimirAlertManagerConfig")
+	} else if r.dryRun(amc) {
+		amc.Status.Status = "DryRun"
+		amc.Status.Error = ""
 	} else {
 		amc.Status.Status = "Synced"
 		amc.Status.Error = ""
+		amc.Status.LastSyncTime = &metav1.Time{Time: time.Now()}
+	}
+
+	// The plan is only kept while it describes the change the MimirAlertManagerConfig would make
+	plan := ""
+	if err != nil || !r.dryRun(amc) {
+		amc.Status.Plan = ""
+	} else if amc.Status.Plan != "" {
+		plan = describePlan(amc.Status.Plan)
+	}
+
+	if err != nil {
+		r.Recorder.Event(amc, corev1.EventTypeWarning, utils.SyncReason(err), err.Error())
 	}
 
+	amc.Status.ObservedGeneration = amc.Generation
+	utils.SetSyncConditions(&amc.Status.Conditions, amc.Generation, err)
+	utils.SetSuspendedCondition(&amc.Status.Conditions, amc.Generation, "")
+	utils.SetDryRunCondition(&amc.Status.Conditions, amc.Generation, plan)
+
+	metrics.SetSyncStatus(domain.MimirAlertManagerConfigKind, amc.Namespace, amc.Name, amc.Spec.ID, amc.Status.Status)
+
 	return r.Status().Update(context.Background(), amc)
 }
 
+// setSuspended updates the status of a suspended MimirAlertManagerConfig
+// The status is only updated when it changes, as the MimirAlertManagerConfig is still reconciled when its dependencies change
+func (r *MimirAlertManagerConfigReconciler) setSuspended(ctx context.Context, amc *domain.MimirAlertManagerConfig) error {
+	message := utils.SuspendedMessage
+
+	condition := meta.FindStatusCondition(amc.Status.Conditions, domain.ConditionSuspended)
+	if amc.Status.Status == "Suspended" && condition != nil && condition.Status == metav1.ConditionTrue &&
+		condition.Message == message && condition.ObservedGeneration == amc.Generation {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("MimirAlertManagerConfig synchronization suspended")
+
+	amc.Status.Status = "Suspended"
+	amc.Status.Error = ""
+	amc.Status.ObservedGeneration = amc.Generation
+	utils.SetSuspendedCondition(&amc.Status.Conditions, amc.Generation, message)
+
+	metrics.SetSyncStatus(domain.MimirAlertManagerConfigKind, amc.Namespace, amc.Name, amc.Spec.ID, amc.Status.Status)
+	r.Recorder.Event(amc, corev1.EventTypeNormal, domain.ReasonSuspended, message)
+
+	return r.Status().Update(context.Background(), amc)
+}
+
+// releaseSuspended removes the finalizer of a MimirAlertManagerConfig deleted while it is suspended
+// Its configuration is left in Mimir, as with the Orphan deletion policy: resuming a resource being deleted isn't possible
+func (r *MimirAlertManagerConfigReconciler) releaseSuspended(ctx context.Context, amc *domain.MimirAlertManagerConfig) error {
+	if !controllerutil.ContainsFinalizer(amc, alertManagerFinalizer) {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Suspended MimirAlertManagerConfig deleted, leaving its configuration in Mimir")
+	r.Recorder.Event(amc, corev1.EventTypeWarning, domain.ReasonOrphaned, utils.SuspendedDeletionMessage)
+	metrics.Forget(domain.MimirAlertManagerConfigKind, amc.Namespace, amc.Name)
+
+	controllerutil.RemoveFinalizer(amc, alertManagerFinalizer)
+	return r.Update(ctx, amc)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *MimirAlertManagerConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&domain.MimirAlertManagerConfig{}).
+	// Index MimirAlertManagerConfigs by the connection they reference, so they can be found when that connection changes
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &domain.MimirAlertManagerConfig{}, mimirconnection.ConnectionRefIndex, func(o client.Object) []string {
+		amc := o.(*domain.MimirAlertManagerConfig)
+		if value := mimirconnection.RefIndexValue(amc.Namespace, amc.Spec.ConnectionRef); value != "" {
+			return []string{value}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Index MimirAlertManagerConfigs by the Secrets and ConfigMaps they reference, so they can be found when one of them changes
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &domain.MimirAlertManagerConfig{}, mimirconnection.ReferenceIndex, func(o client.Object) []string {
+		amc := o.(*domain.MimirAlertManagerConfig)
+		return mimirconnection.ReferenceIndexValues(amc.Namespace, amc.Spec.Auth, amc.Spec.Headers)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Synchronize again when the settings of the Mimir instance referenced through a connection change
+	reconcileOnConnectionChange := handler.EnqueueRequestsFromMapFunc(mimirconnection.EnqueueDependents(r.Client, func() client.ObjectList {
+		return &domain.MimirAlertManagerConfigList{}
+	}))
+
+	// Synchronize again when a referenced Secret or ConfigMap changes, or when a grant allowing a cross-namespace reference changes
+	reconcileOnReferenceChange := mimirconnection.EnqueueReferenceDependents(r.Client, func() client.ObjectList {
+		return &domain.MimirAlertManagerConfigList{}
+	})
+
+	// Status updates are ignored, as every synchronization updates the status and would trigger another one
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&domain.MimirAlertManagerConfig{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(
+			&domain.MimirConnection{},
+			reconcileOnConnectionChange,
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(
+			&domain.ClusterMimirConnection{},
+			reconcileOnConnectionChange,
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	return utils.WatchReferences(b, r.Connections.References, reconcileOnReferenceChange).
 		Complete(r)
 }
@@ -0,0 +1,302 @@
+package mimiralertmanagerconfig
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirconnection"
+)
+
+// fakeAlertmanager is a Mimir Alertmanager API holding the configuration of a tenant in memory
+type fakeAlertmanager struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	config   *string
+	requests []string
+	failing  bool
+}
+
+func newFakeAlertmanager(t *testing.T) *fakeAlertmanager {
+	am := &fakeAlertmanager{}
+	am.Server = httptest.NewServer(http.HandlerFunc(am.serve))
+	t.Cleanup(am.Close)
+
+	return am
+}
+
+func (f *fakeAlertmanager) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method)
+	if f.failing {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if f.config == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, err := yaml.Marshal(&configCompat{AlertmanagerConfig: *f.config})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(body)
+	case http.MethodPost:
+		body, err := io.ReadAll(r.Body)
+		compat := configCompat{}
+		if err == nil {
+			err = yaml.Unmarshal(body, &compat)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.config = &compat.AlertmanagerConfig
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		f.config = nil
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// configCompat is the payload of the Alertmanager API of Mimir
+type configCompat struct {
+	AlertmanagerConfig string `yaml:"alertmanager_config"`
+}
+
+// currentConfig returns the configuration of the tenant and whether it has one
+func (f *fakeAlertmanager) currentConfig() (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.config == nil {
+		return "", false
+	}
+	return *f.config, true
+}
+
+// setConfig changes the configuration of the tenant, as if it was changed outside the operator
+func (f *fakeAlertmanager) setConfig(config string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.config = &config
+}
+
+// takeRequests returns the methods of the requests received since the last call
+func (f *fakeAlertmanager) takeRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	requests := f.requests
+	f.requests = nil
+
+	return requests
+}
+
+func (f *fakeAlertmanager) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failing = failing
+}
+
+// newTestReconciler returns a reconciler working on a fake client holding the given objects
+func newTestReconciler(t *testing.T, objs ...client.Object) *MimirAlertManagerConfigReconciler {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, domain.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatalf("failed to build scheme: %v", err)
+		}
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&domain.MimirAlertManagerConfig{}).
+		Build()
+
+	return &MimirAlertManagerConfigReconciler{
+		Client:                c,
+		Scheme:                scheme,
+		Connections:           &mimirconnection.Resolver{Client: c},
+		Recorder:              &record.FakeRecorder{},
+		DriftRepair:           true,
+		DefaultDeletionPolicy: domain.DeletionPolicyDelete,
+	}
+}
+
+func newAlertManagerConfig(name, url, config string) *domain.MimirAlertManagerConfig {
+	return &domain.MimirAlertManagerConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       domain.MimirAlertManagerConfigSpec{ID: "tenant", URL: url, Config: config},
+	}
+}
+
+// runReconcile runs a reconciliation of a MimirAlertManagerConfig and returns it once reconciled
+func runReconcile(t *testing.T, r *MimirAlertManagerConfigReconciler, name string) *domain.MimirAlertManagerConfig {
+	t.Helper()
+
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile %s: %v", name, err)
+	}
+
+	amc := &domain.MimirAlertManagerConfig{}
+	if err := r.Get(context.Background(), key, amc); err != nil {
+		t.Fatalf("failed to get %s: %v", name, err)
+	}
+
+	return amc
+}
+
+// update changes a MimirAlertManagerConfig with the fake client
+func update(t *testing.T, r *MimirAlertManagerConfigReconciler, amc *domain.MimirAlertManagerConfig, mutate func()) {
+	t.Helper()
+
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(amc), amc); err != nil {
+		t.Fatalf("failed to get %s: %v", amc.Name, err)
+	}
+	mutate()
+	if err := r.Update(context.Background(), amc); err != nil {
+		t.Fatalf("failed to update %s: %v", amc.Name, err)
+	}
+}
+
+func TestReconcilePushesConfig(t *testing.T) {
+	am := newFakeAlertmanager(t)
+	amc := newAlertManagerConfig("config", am.URL, "route: a")
+	r := newTestReconciler(t, amc)
+
+	amc = runReconcile(t, r, "config")
+
+	if amc.Status.Status != "Synced" {
+		t.Fatalf("expected the configuration to be synced, got %s: %s", amc.Status.Status, amc.Status.Error)
+	}
+	if config, _ := am.currentConfig(); config != "route: a" {
+		t.Errorf("expected the configuration to be pushed, got %q", config)
+	}
+	if amc.Status.Digest != configDigest("route: a") {
+		t.Errorf("expected the digest of the pushed configuration to be recorded, got %q", amc.Status.Digest)
+	}
+	am.takeRequests()
+
+	// An unchanged configuration is only compared with the one of Mimir
+	runReconcile(t, r, "config")
+
+	if requests := am.takeRequests(); !slices.Equal(requests, []string{http.MethodGet}) {
+		t.Errorf("expected the unchanged configuration not to be pushed, got %v", requests)
+	}
+
+	// A changed configuration is pushed again
+	update(t, r, amc, func() { amc.Spec.Config = "route: b" })
+	runReconcile(t, r, "config")
+
+	if config, _ := am.currentConfig(); config != "route: b" {
+		t.Errorf("expected the changed configuration to be pushed, got %q", config)
+	}
+}
+
+func TestDeletionPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy        string
+		defaultPolicy string
+		kept          bool
+	}{
+		"delete":         {policy: domain.DeletionPolicyDelete, defaultPolicy: domain.DeletionPolicyOrphan},
+		"orphan":         {policy: domain.DeletionPolicyOrphan, defaultPolicy: domain.DeletionPolicyDelete, kept: true},
+		"default delete": {defaultPolicy: domain.DeletionPolicyDelete},
+		"default orphan": {defaultPolicy: domain.DeletionPolicyOrphan, kept: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			am := newFakeAlertmanager(t)
+			amc := newAlertManagerConfig("config", am.URL, "route: a")
+			amc.Spec.DeletionPolicy = test.policy
+			r := newTestReconciler(t, amc)
+			r.DefaultDeletionPolicy = test.defaultPolicy
+
+			runReconcile(t, r, "config")
+
+			if err := r.Delete(context.Background(), amc); err != nil {
+				t.Fatalf("failed to delete the MimirAlertManagerConfig: %v", err)
+			}
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(amc)}); err != nil {
+				t.Fatalf("failed to reconcile the deletion: %v", err)
+			}
+
+			if _, exists := am.currentConfig(); exists != test.kept {
+				t.Errorf("expected the configuration to be kept in Mimir: %v, got %v", test.kept, exists)
+			}
+			if err := r.Get(context.Background(), client.ObjectKeyFromObject(amc), amc); err == nil {
+				t.Errorf("expected the MimirAlertManagerConfig to be released")
+			}
+		})
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	am := newFakeAlertmanager(t)
+	amc := newAlertManagerConfig("config", am.URL, "route: a")
+	r := newTestReconciler(t, amc)
+
+	runReconcile(t, r, "config")
+	am.takeRequests()
+
+	update(t, r, amc, func() {
+		amc.Spec.Suspend = true
+		amc.Spec.Config = "route: b"
+	})
+	amc = runReconcile(t, r, "config")
+
+	if requests := am.takeRequests(); len(requests) != 0 {
+		t.Errorf("expected nothing to be sent to Mimir, got %v", requests)
+	}
+	if amc.Status.Status != "Suspended" || !meta.IsStatusConditionTrue(amc.Status.Conditions, domain.ConditionSuspended) {
+		t.Errorf("expected the MimirAlertManagerConfig to be reported as suspended, got %s and %+v", amc.Status.Status, amc.Status.Conditions)
+	}
+
+	// The configuration of a suspended MimirAlertManagerConfig is left in Mimir when it is deleted, without waiting for it to be resumed
+	if err := r.Delete(context.Background(), amc); err != nil {
+		t.Fatalf("failed to delete the MimirAlertManagerConfig: %v", err)
+	}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(amc)}); err != nil {
+		t.Fatalf("failed to reconcile the deletion: %v", err)
+	}
+
+	if requests := am.takeRequests(); len(requests) != 0 {
+		t.Errorf("expected nothing to be sent to Mimir, got %v", requests)
+	}
+	if config, _ := am.currentConfig(); config != "route: a" {
+		t.Errorf("expected the configuration to be left in Mimir, got %q", config)
+	}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(amc), amc); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the suspended MimirAlertManagerConfig to be released, got %v", err)
+	}
+}
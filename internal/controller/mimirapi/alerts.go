@@ -8,7 +8,10 @@ package mimirapi
 import (
 	"bytes"
 	"context"
+	"io"
 
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
@@ -29,7 +32,7 @@ func (r *MimirClient) CreateAlertmanagerConfig(ctx context.Context, cfg string,
 		return err
 	}
 
-	res, err := r.doRequest(ctx, alertmanagerAPIPath, "POST", bytes.NewBuffer(payload), int64(len(payload)))
+	res, err := r.doRequest(ctx, alertmanagerAPIPath, alertmanagerAPIPath, "POST", bytes.NewBuffer(payload), int64(len(payload)))
 	if err != nil {
 		return err
 	}
@@ -39,9 +42,36 @@ func (r *MimirClient) CreateAlertmanagerConfig(ctx context.Context, cfg string,
 	return nil
 }
 
+// GetAlertmanagerConfig retrieves an alertmanager config
+func (r *MimirClient) GetAlertmanagerConfig(ctx context.Context) (string, map[string]string, error) {
+	res, err := r.doRequest(ctx, alertmanagerAPIPath, alertmanagerAPIPath, "GET", nil, -1)
+	if err != nil {
+		log.Debugln("no alert config present in response")
+		return "", nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	compat := configCompat{}
+	err = yaml.Unmarshal(body, &compat)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal alertmanager config from response")
+
+		return "", nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	return compat.AlertmanagerConfig, compat.TemplateFiles, nil
+}
+
 // DeleteAlermanagerConfig deletes the users alertmanagerconfig
 func (r *MimirClient) DeleteAlermanagerConfig(ctx context.Context) error {
-	res, err := r.doRequest(ctx, alertmanagerAPIPath, "DELETE", nil, -1)
+	res, err := r.doRequest(ctx, alertmanagerAPIPath, alertmanagerAPIPath, "DELETE", nil, -1)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,163 @@
+package mimirapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+// ErrCircuitOpen is returned when requests to a Mimir endpoint are refused because it kept failing
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned when the circuit breaker of an endpoint refuses a request
+// It matches ErrCircuitOpen with errors.Is
+type CircuitOpenError struct {
+	Endpoint string
+
+	// RetryAfter is how long until the circuit breaker lets a request through again
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("requests to %s are suspended for %s: %s", e.Endpoint, e.RetryAfter.Round(time.Second), ErrCircuitOpen)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitOpenDelay returns how long to wait before sending requests again if err was caused by an open circuit breaker
+func CircuitOpenDelay(err error) (time.Duration, bool) {
+	var circuitOpen *CircuitOpenError
+	if errors.As(err, &circuitOpen) {
+		return circuitOpen.RetryAfter, true
+	}
+
+	return 0, false
+}
+
+// EndpointLimits is used to protect a Mimir endpoint from the requests of the operator.
+// Limits are shared by every client of a Pool sending requests to the same endpoint.
+type EndpointLimits struct {
+	// RateLimit is the number of requests per second sent to an endpoint, requests are not limited if it is 0
+	RateLimit float64 `yaml:"rate_limit"`
+
+	// RateBurst is the number of requests that can be sent at once above RateLimit
+	RateBurst int `yaml:"rate_burst"`
+
+	// BreakerThreshold is the number of consecutive failures after which requests to an endpoint are suspended,
+	// the circuit breaker is disabled if it is 0
+	BreakerThreshold int `yaml:"breaker_threshold"`
+
+	// BreakerCooldown is how long requests are suspended before a request is sent to check if the endpoint recovered
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown"`
+}
+
+// endpointGuard rate limits the requests sent to an endpoint and suspends them when the endpoint keeps failing
+type endpointGuard struct {
+	endpoint string
+	limiter  *rate.Limiter
+	limits   EndpointLimits
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// newEndpointGuard returns the guard of an endpoint with the given limits
+func newEndpointGuard(endpoint string, limits EndpointLimits) *endpointGuard {
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if limits.RateLimit > 0 {
+		burst := limits.RateBurst
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(limits.RateLimit), burst)
+	}
+
+	return &endpointGuard{
+		endpoint: endpoint,
+		limiter:  limiter,
+		limits:   limits,
+	}
+}
+
+// acquire waits for the rate limiter to let a request through, and returns a CircuitOpenError if the
+// circuit breaker refuses it
+// Once the cooldown is over, a single request is let through to check whether the endpoint recovered
+func (g *endpointGuard) acquire(ctx context.Context) error {
+	if err := g.allow(); err != nil {
+		return err
+	}
+
+	if err := g.limiter.Wait(ctx); err != nil {
+		g.release()
+		return err
+	}
+
+	return nil
+}
+
+// allow returns a CircuitOpenError if the circuit breaker refuses a request
+func (g *endpointGuard) allow() error {
+	if g.limits.BreakerThreshold <= 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failures < g.limits.BreakerThreshold {
+		return nil
+	}
+
+	remaining := g.limits.BreakerCooldown - time.Since(g.openedAt)
+	if remaining > 0 || g.probing {
+		if remaining <= 0 {
+			remaining = g.limits.BreakerCooldown
+		}
+		breakerRejectionsTotal.WithLabelValues(g.endpoint).Inc()
+		return &CircuitOpenError{Endpoint: g.endpoint, RetryAfter: remaining}
+	}
+
+	// Half-open: let this request through to probe the endpoint
+	g.probing = true
+
+	return nil
+}
+
+// record updates the circuit breaker with the outcome of a request
+func (g *endpointGuard) record(failed bool) {
+	if g.limits.BreakerThreshold <= 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.probing = false
+
+	if !failed {
+		g.failures = 0
+		return
+	}
+
+	g.failures++
+	if g.failures >= g.limits.BreakerThreshold {
+		// Opening the breaker again after a failed probe restarts the cooldown
+		g.openedAt = time.Now()
+	}
+}
+
+// release lets another request probe the endpoint when a probing request was not sent or was canceled
+func (g *endpointGuard) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.probing = false
+}
@@ -0,0 +1,36 @@
+package mimirapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	client, calls := newTestClient(t, RetryConfig{}, 503, 503, 200)
+	client.guard = newEndpointGuard(client.endpoint.Host, EndpointLimits{BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		if err := client.Ping(context.Background()); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected a server error, got %v", err)
+		}
+	}
+
+	err := client.Ping(context.Background())
+	if delay, ok := CircuitOpenDelay(err); !ok || delay <= 0 {
+		t.Fatalf("expected the circuit breaker to be open, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected no request while the circuit breaker is open, got %d calls", calls.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("expected the probe to succeed, got %v", err)
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("expected the circuit breaker to be closed, got %v", err)
+	}
+}
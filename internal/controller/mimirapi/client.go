@@ -6,9 +6,13 @@
 package mimirapi
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -17,17 +21,26 @@ import (
 	"github.com/grafana/dskit/user"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpproxy"
 )
 
 const (
 	rulerAPIPath  = "/prometheus/config/v1/rules"
 	legacyAPIPath = "/api/v1/rules"
+	readyPath     = "/ready"
+	queryPath     = "/prometheus/api/v1/query"
 )
 
 var (
 	ErrResourceNotFound = errors.New("requested resource not found")
 	errConflict         = errors.New("conflict with current state of target resource")
 	errTooManyRequests  = errors.New("too many requests")
+
+	// ErrUnauthorized is returned when Mimir refuses the credentials of the client (401 or 403)
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrBadRequest is returned when Mimir rejects the content of a request as invalid (400 or 422)
+	ErrBadRequest = errors.New("bad request")
 )
 
 // // UserAgent returns build information in format suitable to be used in HTTP User-Agent header.
@@ -37,11 +50,53 @@ var (
 
 // Config is used to configure a MimirClient.
 type Config struct {
-	User      string `yaml:"user"`
-	Key       string `yaml:"key"`
-	Address   string `yaml:"address"`
-	ID        string `yaml:"id"`
-	AuthToken string `yaml:"auth_token"`
+	User      string       `yaml:"user"`
+	Key       string       `yaml:"key"`
+	Address   string       `yaml:"address"`
+	ID        string       `yaml:"id"`
+	AuthToken string       `yaml:"auth_token"`
+	OAuth2    OAuth2Config `yaml:"oauth2"`
+	TLS       TLSConfig    `yaml:"tls"`
+
+	// Headers are added to every request sent to Mimir
+	Headers map[string]string `yaml:"headers"`
+
+	// ProxyURL is the HTTP proxy used to reach Mimir, the proxy of the environment is used if empty
+	// NoProxy is a comma-separated list of hosts that are reached directly, in the NO_PROXY format
+	ProxyURL string `yaml:"proxy_url"`
+	NoProxy  string `yaml:"no_proxy"`
+
+	// Retry configures how failed requests are retried, they are not retried if it is left empty
+	Retry RetryConfig `yaml:"retry"`
+
+	// Timeouts of the requests sent to Mimir, no timeout is applied if they are left empty
+	Timeouts Timeouts `yaml:"timeouts"`
+
+	// Concurrency is the number of requests sent at once when creating or deleting several rule groups,
+	// they are sent one at a time if it is 0
+	Concurrency int `yaml:"concurrency"`
+}
+
+// Timeouts is used to configure the timeouts of the requests sent to Mimir.
+type Timeouts struct {
+	// Dial is the maximum time spent establishing a TCP connection
+	Dial time.Duration `yaml:"dial"`
+
+	// TLSHandshake is the maximum time spent performing the TLS handshake
+	TLSHandshake time.Duration `yaml:"tls_handshake"`
+
+	// Request is the maximum duration of a request, including reading the response (each retry has its own timeout)
+	Request time.Duration `yaml:"request"`
+}
+
+// TLSConfig is used to configure TLS on the connection to Mimir.
+// CA, Cert and Key hold PEM encoded data.
+type TLSConfig struct {
+	CA                 []byte `yaml:"ca"`
+	Cert               []byte `yaml:"cert"`
+	Key                []byte `yaml:"key"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
 }
 
 // MimirClient is a client to the Mimir API.
@@ -53,11 +108,34 @@ type MimirClient struct {
 	Client       http.Client
 	apiPath      string
 	authToken    string
+	tokens       *tokenCache
 	extraHeaders map[string]string
+	retry        RetryConfig
+	guard        *endpointGuard
+	concurrency  int
 }
 
 // New returns a new MimirClient.
+// Every client returned by New has its own HTTP client, use a Pool to share connections between clients.
 func New(cfg Config) (*MimirClient, error) {
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens *tokenCache
+	if cfg.OAuth2.enabled() {
+		if tokens, err = newTokenCache(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return newMimirClient(cfg, httpClient, tokens)
+}
+
+// newMimirClient returns a new MimirClient sending its requests with the given HTTP client
+// tokens caches the OAuth2 tokens of the client, it is nil when OAuth2 is not configured
+func newMimirClient(cfg Config, httpClient *http.Client, tokens *tokenCache) (*MimirClient, error) {
 	endpoint, err := url.Parse(cfg.Address)
 	if err != nil {
 		return nil, err
@@ -68,26 +146,143 @@ func New(cfg Config) (*MimirClient, error) {
 		"id":      cfg.ID,
 	}).Debugln("New Mimir client created")
 
-	client := http.Client{}
-
 	path := rulerAPIPath
 
 	return &MimirClient{
-		user:      cfg.User,
-		key:       cfg.Key,
-		id:        cfg.ID,
-		endpoint:  endpoint,
-		Client:    client,
-		apiPath:   path,
-		authToken: cfg.AuthToken,
+		user:         cfg.User,
+		key:          cfg.Key,
+		id:           cfg.ID,
+		endpoint:     endpoint,
+		Client:       *httpClient,
+		apiPath:      path,
+		authToken:    cfg.AuthToken,
+		tokens:       tokens,
+		extraHeaders: cfg.Headers,
+		retry:        cfg.Retry,
+		concurrency:  cfg.Concurrency,
 	}, nil
 }
 
+// newHTTPClient returns the HTTP client used to reach Mimir with the TLS, proxy and timeout settings of a configuration
+func newHTTPClient(cfg Config) (*http.Client, error) {
+	tlsConfig, err := buildTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	if cfg.Timeouts.Dial > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   cfg.Timeouts.Dial,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if cfg.Timeouts.TLSHandshake > 0 {
+		transport.TLSHandshakeTimeout = cfg.Timeouts.TLSHandshake
+	}
+
+	if cfg.ProxyURL != "" {
+		if _, err := url.Parse(cfg.ProxyURL); err != nil {
+			return nil, errors.Wrap(err, "invalid proxy url")
+		}
+
+		proxy := (&httpproxy.Config{
+			HTTPProxy:  cfg.ProxyURL,
+			HTTPSProxy: cfg.ProxyURL,
+			NoProxy:    cfg.NoProxy,
+		}).ProxyFunc()
+
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	}
+
+	return &http.Client{Transport: transport, Timeout: cfg.Timeouts.Request}, nil
+}
+
+// buildTLSConfig returns the TLS configuration used by the HTTP client
+func buildTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if len(cfg.CA) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.CA) {
+			return nil, errors.New("failed to parse the CA bundle: no valid PEM certificate found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(cfg.Cert) > 0 || len(cfg.Key) > 0 {
+		if len(cfg.Cert) == 0 || len(cfg.Key) == 0 {
+			return nil, errors.New("both a client certificate and a private key must be provided for mTLS")
+		}
+
+		cert, err := tls.X509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load the client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Target identifies the tenant of the Mimir instance the client sends its requests to, as "<address>|<tenant>"
+// The address is normalized, so that the URLs of a Mimir instance only differing by their form identify the same tenant
+func (r *MimirClient) Target() string {
+	return normalizeAddress(r.endpoint) + "|" + r.id
+}
+
+// NormalizeTarget returns a target recorded by an older version of the operator in the form returned by Target
+// Targets whose address can't be parsed are returned as is
+func NormalizeTarget(target string) string {
+	address, id, found := strings.Cut(target, "|")
+	if !found {
+		return target
+	}
+
+	endpoint, err := url.Parse(address)
+	if err != nil {
+		return target
+	}
+
+	return normalizeAddress(endpoint) + "|" + id
+}
+
+// normalizeAddress returns the address of a Mimir instance with a lowercase scheme and host, without the default port
+// of its scheme, and without trailing slashes
+func normalizeAddress(endpoint *url.URL) string {
+	u := *endpoint
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") { // IPv6 addresses keep their brackets
+		host = "[" + host + "]"
+	}
+	u.Host = host
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	u.Fragment = ""
+
+	return u.String()
+}
+
 // Query executes a PromQL query against the Mimir cluster.
 func (r *MimirClient) Query(ctx context.Context, query string) (*http.Response, error) {
-	req := fmt.Sprintf("/prometheus/api/v1/query?query=%s&time=%d", url.QueryEscape(query), time.Now().Unix())
+	req := fmt.Sprintf("%s?query=%s&time=%d", queryPath, url.QueryEscape(query), time.Now().Unix())
 
-	res, err := r.doRequest(ctx, req, "GET", nil, -1)
+	res, err := r.doRequest(ctx, queryPath, req, "GET", nil, -1)
 	if err != nil {
 		return nil, err
 	}
@@ -95,15 +290,125 @@ func (r *MimirClient) Query(ctx context.Context, query string) (*http.Response,
 	return res, nil
 }
 
-func (r *MimirClient) doRequest(ctx context.Context, path, method string, payload io.Reader, contentLength int64) (*http.Response, error) {
+// Ping checks that the Mimir instance answers on its readiness endpoint.
+func (r *MimirClient) Ping(ctx context.Context) error {
+	res, err := r.doRequest(ctx, readyPath, readyPath, "GET", nil, -1)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	return nil
+}
+
+// doRequest sends a request to Mimir and checks its response
+// Throttled requests, server errors and transient network errors are retried according to the retry policy of the client
+// route is the template of the path (e.g. /prometheus/config/v1/rules/{namespace}), used to label the metrics of the request
+func (r *MimirClient) doRequest(ctx context.Context, route, path, method string, payload io.Reader, contentLength int64) (*http.Response, error) {
+	// The payload is buffered so that the request can be sent again
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = io.ReadAll(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	for retry := 0; ; retry++ {
+		if r.guard != nil {
+			if err := r.guard.acquire(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		start := time.Now()
+		resp, err := r.sendAuthenticatedRequest(ctx, path, method, body, contentLength)
+		observeRequest(r.endpoint.Host, method, route, resp, time.Since(start))
+
+		reason := retryReason(resp, err)
+		r.recordOutcome(ctx, reason)
+
+		if err == nil {
+			if err = checkResponse(resp); err == nil {
+				return resp, nil
+			}
+
+			_ = resp.Body.Close()
+			err = errors.Wrapf(err, "%s request to %s failed", resp.Request.Method, resp.Request.URL.String())
+		}
+
+		if reason == "" || retry >= r.retry.MaxRetries {
+			return nil, err
+		}
+
+		delay := r.retry.backoff(retry, retryAfter(resp))
+
+		log.WithFields(log.Fields{
+			"path":   path,
+			"method": method,
+			"reason": reason,
+			"delay":  delay,
+			"error":  err.Error(),
+		}).Warnln("retrying request to Grafana Mimir API")
+
+		if !wait(ctx, delay) {
+			return nil, err
+		}
+		retriesTotal.WithLabelValues(reason).Inc()
+	}
+}
+
+// recordOutcome reports the outcome of a request to the circuit breaker of the endpoint
+// Server and network errors are failures, any other response shows that the endpoint is up
+func (r *MimirClient) recordOutcome(ctx context.Context, reason string) {
+	if r.guard == nil {
+		return
+	}
+
+	if ctx.Err() != nil { // The request was interrupted, it says nothing about the endpoint
+		r.guard.release()
+		return
+	}
+
+	r.guard.record(reason == retryReasonServerError || reason == retryReasonNetwork)
+}
+
+// sendAuthenticatedRequest sends a request to Mimir, fetching a new OAuth2 token and sending it again
+// if the token was rejected
+func (r *MimirClient) sendAuthenticatedRequest(ctx context.Context, path, method string, body []byte, contentLength int64) (*http.Response, error) {
+	resp, err := r.sendRequest(ctx, path, method, body, contentLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// An OAuth2 token can be revoked or expire before its announced expiry,
+	// fetch a new token and retry once before giving up
+	if resp.StatusCode == http.StatusUnauthorized && r.tokens != nil {
+		_ = resp.Body.Close()
+		r.tokens.invalidate()
+
+		return r.sendRequest(ctx, path, method, body, contentLength)
+	}
+
+	return resp, nil
+}
+
+// sendRequest sends one authenticated request to Mimir
+func (r *MimirClient) sendRequest(ctx context.Context, path, method string, body []byte, contentLength int64) (*http.Response, error) {
+	var payload io.Reader
+	if body != nil {
+		payload = bytes.NewReader(body)
+	}
+
 	req, err := buildRequest(ctx, path, method, *r.endpoint, payload, contentLength)
 	if err != nil {
 		return nil, err
 	}
 
 	switch {
-	case (r.user != "" || r.key != "") && r.authToken != "":
-		err := errors.New("at most one of basic auth or auth token should be configured")
+	case (r.user != "" || r.key != "") && (r.authToken != "" || r.tokens != nil), r.authToken != "" && r.tokens != nil:
+		err := errors.New("at most one of basic auth, auth token or oauth2 should be configured")
 		log.WithFields(log.Fields{
 			"url":    req.URL.String(),
 			"method": req.Method,
@@ -119,6 +424,13 @@ func (r *MimirClient) doRequest(ctx context.Context, path, method string, payloa
 
 	case r.authToken != "":
 		req.Header.Add("Authorization", "Bearer "+r.authToken)
+
+	case r.tokens != nil:
+		token, err := r.tokens.Token(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to obtain oauth2 token")
+		}
+		req.Header.Add("Authorization", "Bearer "+token)
 	}
 
 	for k, v := range r.extraHeaders {
@@ -142,11 +454,6 @@ func (r *MimirClient) doRequest(ctx context.Context, path, method string, payloa
 		return nil, err
 	}
 
-	if err := checkResponse(resp); err != nil {
-		_ = resp.Body.Close()
-		return nil, errors.Wrapf(err, "%s request to %s failed", req.Method, req.URL.String())
-	}
-
 	return resp, nil
 }
 
@@ -199,6 +506,13 @@ func checkResponse(r *http.Response) error {
 		errMsg = fmt.Sprintf("server returned HTTP status: %s, body: %q", r.Status, bodyStr)
 	}
 
+	switch r.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrUnauthorized, errMsg)
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: %s", ErrBadRequest, errMsg)
+	}
+
 	return errors.New(errMsg)
 }
 
@@ -0,0 +1,222 @@
+package mimirapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	stdlog "log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestCertificate returns a self-signed client certificate and its private key, PEM encoded
+func newTestCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	cert, key := newTestCertificate(t)
+	_, otherKey := newTestCertificate(t)
+
+	tests := map[string]struct {
+		cfg     TLSConfig
+		invalid bool
+	}{
+		"empty":                {cfg: TLSConfig{}},
+		"ca":                   {cfg: TLSConfig{CA: cert}},
+		"client certificate":   {cfg: TLSConfig{Cert: cert, Key: key}},
+		"invalid ca":           {cfg: TLSConfig{CA: []byte("not a certificate")}, invalid: true},
+		"certificate only":     {cfg: TLSConfig{Cert: cert}, invalid: true},
+		"key only":             {cfg: TLSConfig{Key: key}, invalid: true},
+		"mismatched key":       {cfg: TLSConfig{Cert: cert, Key: otherKey}, invalid: true},
+		"server name and skip": {cfg: TLSConfig{ServerName: "mimir.example", InsecureSkipVerify: true}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tlsConfig, err := buildTLSConfig(test.cfg)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if (tlsConfig.RootCAs != nil) != (len(test.cfg.CA) > 0) {
+				t.Errorf("expected the CA bundle to replace the system pool only when set")
+			}
+			if (len(tlsConfig.Certificates) > 0) != (len(test.cfg.Cert) > 0) {
+				t.Errorf("expected the client certificate to be presented only when set")
+			}
+			if tlsConfig.ServerName != test.cfg.ServerName || tlsConfig.InsecureSkipVerify != test.cfg.InsecureSkipVerify {
+				t.Errorf("expected the server name and verification settings to be kept, got %q and %v", tlsConfig.ServerName, tlsConfig.InsecureSkipVerify)
+			}
+		})
+	}
+}
+
+func TestTLSConnection(t *testing.T) {
+	var clientCertificates int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCertificates = len(r.TLS.PeerCertificates)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	cert, key := newTestCertificate(t)
+
+	tests := map[string]struct {
+		cfg          TLSConfig
+		certificates int
+		invalid      bool
+	}{
+		"unknown authority":    {cfg: TLSConfig{}, invalid: true},
+		"ca":                   {cfg: TLSConfig{CA: ca}},
+		"insecure skip verify": {cfg: TLSConfig{InsecureSkipVerify: true}},
+		"matching server name": {cfg: TLSConfig{CA: ca, ServerName: "example.com"}},
+		"other server name":    {cfg: TLSConfig{CA: ca, ServerName: "mimir.example"}, invalid: true},
+		"client certificate":   {cfg: TLSConfig{CA: ca, Cert: cert, Key: key}, certificates: 1},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := New(Config{Address: server.URL, ID: "tenant", TLS: test.cfg})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			clientCertificates = 0
+			err = client.Ping(context.Background())
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected the TLS handshake to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if clientCertificates != test.certificates {
+				t.Errorf("expected %d client certificates, got %d", test.certificates, clientCertificates)
+			}
+		})
+	}
+}
+
+func TestProxy(t *testing.T) {
+	tests := map[string]struct {
+		cfg     Config
+		target  string
+		proxy   string
+		invalid bool
+	}{
+		"proxied": {
+			cfg:    Config{ProxyURL: "http://proxy.example:3128", NoProxy: "internal.example"},
+			target: "http://mimir.example/ready",
+			proxy:  "http://proxy.example:3128",
+		},
+		"no proxy": {
+			cfg:    Config{ProxyURL: "http://proxy.example:3128", NoProxy: "internal.example"},
+			target: "http://internal.example/ready",
+		},
+		"invalid proxy url": {
+			cfg:     Config{ProxyURL: "http://proxy.example:port"},
+			invalid: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mc, err := New(test.cfg)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, test.target, nil)
+			proxy, err := mc.Client.Transport.(*http.Transport).Proxy(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if proxy != nil {
+				got = proxy.String()
+			}
+			if got != test.proxy {
+				t.Errorf("expected proxy %q, got %q", test.proxy, got)
+			}
+		})
+	}
+}
+
+func TestTarget(t *testing.T) {
+	tests := map[string]struct {
+		address  string
+		expected string
+	}{
+		"plain":                {address: "http://mimir", expected: "http://mimir|tenant"},
+		"trailing slash":       {address: "http://mimir/", expected: "http://mimir|tenant"},
+		"trailing slashes":     {address: "http://mimir/prometheus//", expected: "http://mimir/prometheus|tenant"},
+		"uppercase":            {address: "HTTP://Mimir.Example", expected: "http://mimir.example|tenant"},
+		"default http port":    {address: "http://mimir:80/", expected: "http://mimir|tenant"},
+		"default https port":   {address: "https://mimir:443", expected: "https://mimir|tenant"},
+		"other port":           {address: "http://mimir:8080/", expected: "http://mimir:8080|tenant"},
+		"ipv6":                 {address: "http://[::1]:80/", expected: "http://[::1]|tenant"},
+		"ipv6 with other port": {address: "http://[::1]:8080", expected: "http://[::1]:8080|tenant"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := New(Config{Address: test.address, ID: "tenant"})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			if got := client.Target(); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+			if got := NormalizeTarget(test.address + "|tenant"); got != test.expected {
+				t.Errorf("expected the recorded target to be normalized to %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
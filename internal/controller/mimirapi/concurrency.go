@@ -0,0 +1,33 @@
+package mimirapi
+
+import (
+	"sync"
+)
+
+// forEach calls fn for each item, with at most concurrency calls running at once, and returns the error
+// returned for each item
+// Items are processed one at a time if concurrency is lower than 1
+func forEach[T any](concurrency int, items []T, fn func(T) error) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, item := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(i int, item T) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = fn(item)
+		}(i, item)
+	}
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,54 @@
+package mimirapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// requestDuration observes the duration of the requests sent to Mimir, retries being observed separately
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mimir_operator_client_request_duration_seconds",
+		Help:    "Duration of the requests sent to the Mimir API.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "method", "path", "code"})
+
+	// requestsTotal counts the requests sent to Mimir
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mimir_operator_client_requests_total",
+		Help: "Total number of requests sent to the Mimir API.",
+	}, []string{"endpoint", "method", "path", "code"})
+
+	// retriesTotal counts the requests to Mimir that were retried, by reason
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mimir_operator_client_retries_total",
+		Help: "Total number of retried requests to the Mimir API, by reason of the retry.",
+	}, []string{"reason"})
+
+	// breakerRejectionsTotal counts the requests refused by the circuit breaker of an endpoint
+	breakerRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mimir_operator_client_circuit_breaker_rejections_total",
+		Help: "Total number of requests to the Mimir API refused because the circuit breaker of the endpoint is open.",
+	}, []string{"endpoint"})
+)
+
+func init() {
+	// Metrics are exposed on the metrics endpoint of the manager
+	metrics.Registry.MustRegister(requestDuration, requestsTotal, retriesTotal, breakerRejectionsTotal)
+}
+
+// observeRequest records a request sent to Mimir
+// The code label is "error" for requests that got no response
+func observeRequest(endpoint, method, route string, resp *http.Response, duration time.Duration) {
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+
+	requestDuration.WithLabelValues(endpoint, method, route, code).Observe(duration.Seconds())
+	requestsTotal.WithLabelValues(endpoint, method, route, code).Inc()
+}
@@ -0,0 +1,41 @@
+package mimirapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	client, _ := newTestClient(t, RetryConfig{MaxRetries: 1, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, http.StatusServiceUnavailable, http.StatusOK)
+	endpoint := client.endpoint.Host
+	retries := testutil.ToFloat64(retriesTotal.WithLabelValues(retryReasonServerError))
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues(endpoint, http.MethodGet, readyPath, "503")); got != 1 {
+		t.Errorf("expected 1 failed request, got %v", got)
+	}
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues(endpoint, http.MethodGet, readyPath, "200")); got != 1 {
+		t.Errorf("expected 1 successful request, got %v", got)
+	}
+	if got := testutil.ToFloat64(retriesTotal.WithLabelValues(retryReasonServerError)) - retries; got != 1 {
+		t.Errorf("expected 1 retry, got %v", got)
+	}
+	if got := testutil.CollectAndCount(requestDuration); got < 2 {
+		t.Errorf("expected the duration of the requests to be observed, got %d series", got)
+	}
+}
+
+func TestObserveRequestWithoutResponse(t *testing.T) {
+	observeRequest("unreachable", http.MethodGet, readyPath, nil, time.Second)
+
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues("unreachable", http.MethodGet, readyPath, "error")); got != 1 {
+		t.Errorf("expected the request to be counted as an error, got %v", got)
+	}
+}
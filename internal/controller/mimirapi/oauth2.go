@@ -0,0 +1,102 @@
+package mimirapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// tokenEarlyExpiry is how long before its announced expiry a cached token is refreshed
+const tokenEarlyExpiry = time.Minute
+
+// OAuth2Config is used to authenticate on Mimir with tokens obtained through the OAuth2 client credentials flow.
+type OAuth2Config struct {
+	TokenURL       string            `yaml:"token_url"`
+	ClientID       string            `yaml:"client_id"`
+	ClientSecret   string            `yaml:"client_secret"`
+	Scopes         []string          `yaml:"scopes"`
+	EndpointParams map[string]string `yaml:"endpoint_params"`
+	// TLS is used to reach the token endpoint, the TLS settings of Mimir are never used with it
+	TLS TLSConfig `yaml:"tls"`
+}
+
+// enabled returns true if the OAuth2 flow is configured
+func (c OAuth2Config) enabled() bool {
+	return c.TokenURL != ""
+}
+
+// tokenCache caches the token of an OAuth2 client and fetches a new one shortly before it expires
+// A tokenCache belongs to one HTTP client: the Pool keys its HTTP clients on the OAuth2 settings as well,
+// so tokens are shared between the tenants of a connection and dropped along with the HTTP client.
+type tokenCache struct {
+	mu         sync.Mutex
+	config     clientcredentials.Config
+	httpClient *http.Client
+	token      *oauth2.Token
+}
+
+// newTokenCache returns an empty token cache for the OAuth2 settings of cfg
+// The token endpoint is reached with its own HTTP client: a CA bundle of Mimir would reject public identity providers,
+// and the client certificate of Mimir must not be presented to them. It only shares the proxy and timeouts of Mimir.
+func newTokenCache(cfg Config) (*tokenCache, error) {
+	tokenCfg := cfg
+	tokenCfg.TLS = cfg.OAuth2.TLS
+	httpClient, err := newHTTPClient(tokenCfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid oauth2 settings")
+	}
+
+	return newTokenCacheWithClient(cfg.OAuth2, httpClient), nil
+}
+
+// newTokenCacheWithClient returns an empty token cache reaching the token endpoint with httpClient
+func newTokenCacheWithClient(cfg OAuth2Config, httpClient *http.Client) *tokenCache {
+	params := make(map[string][]string, len(cfg.EndpointParams))
+	for k, v := range cfg.EndpointParams {
+		params[k] = []string{v}
+	}
+
+	return &tokenCache{
+		config: clientcredentials.Config{
+			ClientID:       cfg.ClientID,
+			ClientSecret:   cfg.ClientSecret,
+			TokenURL:       cfg.TokenURL,
+			Scopes:         cfg.Scopes,
+			EndpointParams: params,
+		},
+		httpClient: httpClient,
+	}
+}
+
+// Token returns the cached token, or fetches a new one if it expires soon
+// The token is fetched from the token endpoint directly rather than through an oauth2.TokenSource,
+// which would keep handing out its own cached token after invalidate
+func (c *tokenCache) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != nil && (c.token.Expiry.IsZero() || time.Until(c.token.Expiry) > tokenEarlyExpiry) {
+		return c.token.AccessToken, nil
+	}
+
+	token, err := c.config.Token(context.WithValue(ctx, oauth2.HTTPClient, c.httpClient))
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+
+	return token.AccessToken, nil
+}
+
+// invalidate drops the cached token so that the next call to Token fetches a new one
+func (c *tokenCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = nil
+}
@@ -0,0 +1,154 @@
+package mimirapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newTokenServer returns a token endpoint issuing a new token on every request, valid for expiresIn seconds
+func newTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	var issued atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &issued
+}
+
+func TestOAuth2RetryFetchesNewToken(t *testing.T) {
+	tokenServer, issued := newTokenServer(t, 3600)
+
+	// The first token is rejected, any other one is accepted
+	var seen []string
+	mimir := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		seen = append(seen, auth)
+		if auth == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(mimir.Close)
+
+	client, err := New(Config{Address: mimir.URL, ID: "tenant", OAuth2: OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id"}})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 2 || seen[1] != "Bearer token-2" {
+		t.Errorf("expected the request to be retried with a new token, got %v", seen)
+	}
+	if got := issued.Load(); got != 2 {
+		t.Errorf("expected 2 tokens to be issued, got %d", got)
+	}
+}
+
+func TestOAuth2TLS(t *testing.T) {
+	var clientCertificates atomic.Int32
+	tokenServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCertificates.Store(int32(len(r.TLS.PeerCertificates)))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"token","token_type":"bearer","expires_in":3600}`)
+	}))
+	tokenServer.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	tokenServer.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	tokenServer.StartTLS()
+	t.Cleanup(tokenServer.Close)
+
+	mimir := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	t.Cleanup(mimir.Close)
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tokenServer.Certificate().Raw})
+	cert, key := newTestCertificate(t)
+
+	tests := map[string]struct {
+		tls     TLSConfig
+		invalid bool
+	}{
+		// The CA bundle of Mimir would be enough to reach the token endpoint, but it is not used for it
+		"mimir settings only": {invalid: true},
+		"oauth2 settings":     {tls: TLSConfig{CA: ca}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := New(Config{
+				Address: mimir.URL,
+				ID:      "tenant",
+				TLS:     TLSConfig{CA: ca, Cert: cert, Key: key},
+				OAuth2:  OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id", TLS: test.tls},
+			})
+			if err != nil {
+				t.Fatalf("failed to create client: %v", err)
+			}
+
+			clientCertificates.Store(-1)
+			err = client.Ping(context.Background())
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected the token endpoint to be unreachable")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := clientCertificates.Load(); got != 0 {
+				t.Errorf("expected the client certificate of Mimir not to be sent to the token endpoint, got %d certificates", got)
+			}
+		})
+	}
+}
+
+func TestOAuth2TokenRefreshedBeforeExpiry(t *testing.T) {
+	// Tokens expiring within tokenEarlyExpiry are never reused
+	tokenServer, issued := newTokenServer(t, 30)
+
+	cache := newTokenCacheWithClient(OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id"}, http.DefaultClient)
+	for i := 1; i <= 2; i++ {
+		token, err := cache.Token(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := fmt.Sprintf("token-%d", i); token != want {
+			t.Errorf("expected %s, got %s", want, token)
+		}
+	}
+
+	if got := issued.Load(); got != 2 {
+		t.Errorf("expected 2 tokens to be issued, got %d", got)
+	}
+}
+
+func TestPoolSharesTokensPerHTTPClient(t *testing.T) {
+	tokenServer, _ := newTokenServer(t, 3600)
+	pool := NewPool(Timeouts{}, 0, EndpointLimits{})
+
+	cfg := Config{Address: "http://mimir", OAuth2: OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id"}}
+	a, _ := pool.Get("owner", Config{Address: cfg.Address, ID: "a", OAuth2: cfg.OAuth2})
+	b, _ := pool.Get("owner", Config{Address: cfg.Address, ID: "b", OAuth2: cfg.OAuth2})
+	if a.tokens != b.tokens {
+		t.Errorf("expected the tenants of a connection to share their tokens")
+	}
+
+	pool.Invalidate("owner")
+	c, _ := pool.Get("owner", Config{Address: cfg.Address, ID: "a", OAuth2: cfg.OAuth2})
+	if c.tokens == a.tokens {
+		t.Errorf("expected the tokens to be dropped along with the HTTP client")
+	}
+}
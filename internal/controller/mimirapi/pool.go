@@ -0,0 +1,244 @@
+package mimirapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTimeouts are the timeouts used by a Pool for the settings a configuration leaves empty
+var DefaultTimeouts = Timeouts{
+	Dial:         10 * time.Second,
+	TLSHandshake: 10 * time.Second,
+	Request:      60 * time.Second,
+}
+
+// Pool shares HTTP clients, and therefore their connections and OAuth2 tokens, between the MimirClients created with the same
+// endpoint, authentication, TLS, proxy and timeout settings. Only the tenant of the clients may differ.
+// Unused HTTP clients are evicted once they have been idle for IdleTimeout.
+type Pool struct {
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+
+	// guards holds the rate limiter and circuit breaker of each endpoint, they are kept for the lifetime of the Pool
+	guards map[string]*endpointGuard
+
+	// Timeouts are used for the timeouts a configuration leaves empty
+	Timeouts Timeouts
+
+	// IdleTimeout is how long an HTTP client can stay unused before being evicted
+	IdleTimeout time.Duration
+
+	// Limits protect each endpoint from the requests sent by the clients of the Pool
+	Limits EndpointLimits
+
+	// Concurrency is used for the configurations leaving it empty
+	Concurrency int
+}
+
+// pooledClient is an HTTP client shared by a Pool
+type pooledClient struct {
+	client   *http.Client
+	lastUsed time.Time
+
+	// tokens caches the OAuth2 tokens of the clients, it is nil when OAuth2 is not configured
+	tokens *tokenCache
+
+	// owners lists the connections whose settings were used to create the client
+	owners map[string]struct{}
+}
+
+// NewPool returns an empty Pool using the given default timeouts and endpoint limits
+func NewPool(timeouts Timeouts, idleTimeout time.Duration, limits EndpointLimits) *Pool {
+	return &Pool{
+		clients:     map[string]*pooledClient{},
+		guards:      map[string]*endpointGuard{},
+		Timeouts:    timeouts,
+		IdleTimeout: idleTimeout,
+		Limits:      limits,
+	}
+}
+
+// Get returns a MimirClient for a configuration, reusing the HTTP client of a previous configuration with the same settings
+// owner identifies the connection the settings come from, so that the HTTP clients created from its previous settings
+// can be dropped with Refresh or Invalidate when it changes. It can be left empty for settings that don't come from a connection.
+func (p *Pool) Get(owner string, cfg Config) (*MimirClient, error) {
+	cfg.Timeouts = p.withDefaults(cfg.Timeouts)
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = p.Concurrency
+	}
+
+	key, err := poolKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pooled, ok := p.clients[key]
+	if !ok {
+		client, err := newHTTPClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		pooled = &pooledClient{client: client, owners: map[string]struct{}{}}
+		if cfg.OAuth2.enabled() {
+			if pooled.tokens, err = newTokenCache(cfg); err != nil {
+				return nil, err
+			}
+		}
+		p.clients[key] = pooled
+	}
+
+	pooled.lastUsed = time.Now()
+	if owner != "" {
+		pooled.owners[owner] = struct{}{}
+	}
+
+	client, err := newMimirClient(cfg, pooled.client, pooled.tokens)
+	if err != nil {
+		return nil, err
+	}
+	client.guard = p.guard(client.endpoint.Host)
+
+	return client, nil
+}
+
+// guard returns the rate limiter and circuit breaker of an endpoint, it must be called with the lock held
+func (p *Pool) guard(endpoint string) *endpointGuard {
+	g, ok := p.guards[endpoint]
+	if !ok {
+		g = newEndpointGuard(endpoint, p.Limits)
+		p.guards[endpoint] = g
+	}
+
+	return g
+}
+
+// Invalidate drops the HTTP clients created from the settings of a connection
+// Clients already handed out keep working, new ones are created with fresh connections
+func (p *Pool) Invalidate(owner string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pooled := range p.clients {
+		if _, ok := pooled.owners[owner]; ok {
+			pooled.client.CloseIdleConnections()
+			delete(p.clients, key)
+		}
+	}
+}
+
+// Refresh drops the HTTP clients created from the previous settings of a connection, cfg being its current settings
+// The HTTP client matching the current settings is kept along with its connections, as are the clients still
+// used by other connections. It is a no-op as long as the settings of the connection don't change.
+func (p *Pool) Refresh(owner string, cfg Config) error {
+	cfg.Timeouts = p.withDefaults(cfg.Timeouts)
+
+	current, err := poolKey(cfg)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pooled := range p.clients {
+		if _, ok := pooled.owners[owner]; !ok || key == current {
+			continue
+		}
+
+		delete(pooled.owners, owner)
+		if len(pooled.owners) == 0 {
+			pooled.client.CloseIdleConnections()
+			delete(p.clients, key)
+		}
+	}
+
+	return nil
+}
+
+// Start evicts idle HTTP clients periodically until the context is done
+// It allows the Pool to be added to a controller-runtime manager
+func (p *Pool) Start(ctx context.Context) error {
+	if p.IdleTimeout <= 0 { // Idle clients are never evicted
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(p.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.evictIdle()
+		}
+	}
+}
+
+// NeedLeaderElection returns false, as HTTP clients are used by every replica of the operator
+func (p *Pool) NeedLeaderElection() bool {
+	return false
+}
+
+// evictIdle drops the HTTP clients that have not been used for IdleTimeout
+func (p *Pool) evictIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pooled := range p.clients {
+		if time.Since(pooled.lastUsed) > p.IdleTimeout {
+			pooled.client.CloseIdleConnections()
+			delete(p.clients, key)
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"clients": len(p.clients),
+	}).Debugln("evicted idle Mimir HTTP clients")
+}
+
+// withDefaults returns the given timeouts, using the default timeouts of the Pool for the ones left empty
+func (p *Pool) withDefaults(t Timeouts) Timeouts {
+	if t.Dial == 0 {
+		t.Dial = p.Timeouts.Dial
+	}
+	if t.TLSHandshake == 0 {
+		t.TLSHandshake = p.Timeouts.TLSHandshake
+	}
+	if t.Request == 0 {
+		t.Request = p.Timeouts.Request
+	}
+
+	return t
+}
+
+// poolKey identifies the endpoint, authentication, TLS, proxy and timeout settings of a configuration
+// The tenant, headers and retry policy are left out since they don't depend on the HTTP client
+func poolKey(cfg Config) (string, error) {
+	cfg.ID = ""
+	cfg.Headers = nil
+	cfg.Retry = RetryConfig{}
+	cfg.Concurrency = 0
+
+	// Maps are marshaled with sorted keys, so equal configurations always have the same key
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
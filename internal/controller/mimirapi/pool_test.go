@@ -0,0 +1,61 @@
+package mimirapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolRefresh(t *testing.T) {
+	pool := NewPool(Timeouts{}, time.Hour, EndpointLimits{})
+
+	cfg := Config{Address: "http://mimir", ID: "tenant", AuthToken: "first"}
+	first, err := pool.Get("owner", cfg)
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+
+	// Unchanged settings, such as on periodic checks, keep the HTTP client and its connections
+	if err := pool.Refresh("owner", cfg); err != nil {
+		t.Fatalf("failed to refresh: %v", err)
+	}
+	if len(pool.clients) != 1 {
+		t.Fatalf("expected the HTTP client to be kept, got %d clients", len(pool.clients))
+	}
+	same, _ := pool.Get("owner", cfg)
+	if same.Client.Transport != first.Client.Transport {
+		t.Errorf("expected the HTTP client to be reused")
+	}
+
+	// Rotated credentials drop the HTTP client created from the previous settings
+	rotated := cfg
+	rotated.AuthToken = "second"
+	if err := pool.Refresh("owner", rotated); err != nil {
+		t.Fatalf("failed to refresh: %v", err)
+	}
+	if len(pool.clients) != 0 {
+		t.Errorf("expected the previous HTTP client to be dropped, got %d clients", len(pool.clients))
+	}
+}
+
+func TestPoolRefreshKeepsSharedClients(t *testing.T) {
+	pool := NewPool(Timeouts{}, time.Hour, EndpointLimits{})
+
+	cfg := Config{Address: "http://mimir", ID: "tenant"}
+	_, _ = pool.Get("first", cfg)
+	_, _ = pool.Get("second", cfg)
+
+	// The HTTP client is still used by the other connection
+	changed := cfg
+	changed.AuthToken = "token"
+	if err := pool.Refresh("first", changed); err != nil {
+		t.Fatalf("failed to refresh: %v", err)
+	}
+	if len(pool.clients) != 1 {
+		t.Fatalf("expected the shared HTTP client to be kept, got %d clients", len(pool.clients))
+	}
+	for _, pooled := range pool.clients {
+		if _, ok := pooled.owners["first"]; ok {
+			t.Errorf("expected the refreshed connection to be removed from the owners of the client")
+		}
+	}
+}
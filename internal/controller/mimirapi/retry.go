@@ -0,0 +1,143 @@
+package mimirapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Reasons for which a request to Mimir is retried
+const (
+	retryReasonThrottled   = "throttled"
+	retryReasonServerError = "server_error"
+	retryReasonNetwork     = "network_error"
+)
+
+// RetryConfig is used to configure how failed requests to Mimir are retried.
+// Requests are retried when Mimir throttles them (429), on server errors (5xx) and on transient network errors.
+type RetryConfig struct {
+	// MaxRetries is the maximum number of times a request is retried, requests are not retried if it is 0
+	MaxRetries int `yaml:"max_retries"`
+
+	// MinBackoff is the delay before the first retry, it doubles on every retry
+	MinBackoff time.Duration `yaml:"min_backoff"`
+
+	// MaxBackoff caps the delay between two retries, including delays requested by Mimir through Retry-After
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// DefaultRetryConfig is the retry policy used when none is configured
+var DefaultRetryConfig = RetryConfig{
+	MaxRetries: 3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+}
+
+// backoff returns how long to wait before the given retry (starting at 0)
+// The exponential delay is jittered so that clients throttled at the same time don't retry together,
+// and it is raised to the delay requested by Mimir if there is one
+func (c RetryConfig) backoff(retry int, retryAfter time.Duration) time.Duration {
+	delay := c.MaxBackoff
+	if retry < 32 && c.MinBackoff<<retry < c.MaxBackoff {
+		delay = c.MinBackoff << retry
+	}
+
+	if delay > 0 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	if c.MaxBackoff > 0 && delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+
+	return delay
+}
+
+// retryReason returns why a request should be retried given its response or error, or an empty string
+// if it should not be retried
+func retryReason(resp *http.Response, err error) string {
+	if resp != nil {
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return retryReasonThrottled
+		case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+			return retryReasonServerError
+		default:
+			return ""
+		}
+	}
+
+	if isTransient(err) {
+		return retryReasonNetwork
+	}
+
+	return ""
+}
+
+// isTransient returns true if an error is a network error that may not happen again
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a response, or 0 if there is none
+// The header either holds a number of seconds or an HTTP date
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+// wait blocks for the given delay, or until the context is done
+// It returns false without waiting if the context would expire before the end of the delay
+func wait(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,98 @@
+package mimirapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for a test server answering with the given status codes, one per request
+func newTestClient(t *testing.T, retry RetryConfig, codes ...int) (*MimirClient, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		call := int(calls.Add(1)) - 1
+		code := codes[len(codes)-1]
+		if call < len(codes) {
+			code = codes[call]
+		}
+		if code == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(Config{Address: server.URL, ID: "tenant", Retry: retry})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	return client, &calls
+}
+
+func TestDoRequestRetries(t *testing.T) {
+	retry := RetryConfig{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	tests := []struct {
+		name      string
+		retry     RetryConfig
+		codes     []int
+		wantErr   bool
+		wantCalls int32
+	}{
+		{name: "success", retry: retry, codes: []int{200}, wantCalls: 1},
+		{name: "server error then success", retry: retry, codes: []int{503, 502, 200}, wantCalls: 3},
+		{name: "throttled then success", retry: retry, codes: []int{429, 200}, wantCalls: 2},
+		{name: "retries exhausted", retry: retry, codes: []int{500}, wantErr: true, wantCalls: 4},
+		{name: "client error is not retried", retry: retry, codes: []int{400}, wantErr: true, wantCalls: 1},
+		{name: "retries disabled", retry: RetryConfig{}, codes: []int{503, 200}, wantErr: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, calls := newTestClient(t, tt.retry, tt.codes...)
+
+			err := client.Ping(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestDoRequestStopsWithContext(t *testing.T) {
+	client, calls := newTestClient(t, RetryConfig{MaxRetries: 5, MinBackoff: time.Minute, MaxBackoff: time.Minute}, 503)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected no retry past the deadline of the context, got %d calls", calls.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := RetryConfig{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for retry := 0; retry < 10; retry++ {
+		if delay := cfg.backoff(retry, 0); delay > cfg.MaxBackoff || delay <= 0 {
+			t.Errorf("retry %d: backoff %s out of bounds", retry, delay)
+		}
+	}
+
+	if delay := cfg.backoff(0, 500*time.Millisecond); delay != 500*time.Millisecond {
+		t.Errorf("expected Retry-After to be honored, got %s", delay)
+	}
+	if delay := cfg.backoff(0, time.Hour); delay != cfg.MaxBackoff {
+		t.Errorf("expected Retry-After to be capped, got %s", delay)
+	}
+}
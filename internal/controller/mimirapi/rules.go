@@ -8,16 +8,27 @@ package mimirapi
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"net/url"
 	"slices"
+	"sort"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 
 	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi/rwrulefmt"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi/rwrulefmt/model"
+)
+
+// Templates of the rule paths, appended to the API path to label the metrics of the requests
+const (
+	namespaceRoute = "/{namespace}"
+	groupRoute     = "/{namespace}/{group}"
 )
 
 // ruleElement describes one element returned by Mimir when listing all the rules for a tenant
@@ -36,7 +47,7 @@ func (r *MimirClient) CreateRuleGroup(ctx context.Context, namespace string, rg
 	escapedNamespace := url.PathEscape(namespace)
 	path := r.apiPath + "/" + escapedNamespace
 
-	res, err := r.doRequest(ctx, path, "POST", bytes.NewBuffer(payload), int64(len(payload)))
+	res, err := r.doRequest(ctx, r.apiPath+namespaceRoute, path, "POST", bytes.NewBuffer(payload), int64(len(payload)))
 	if err != nil {
 		return err
 	}
@@ -48,21 +59,44 @@ func (r *MimirClient) CreateRuleGroup(ctx context.Context, namespace string, rg
 
 // CreateRuleGroupStr creates a new rule group from string
 func (r *MimirClient) CreateRuleGroupStr(ctx context.Context, namespace, rg string) error {
-	var rns rwrulefmt.RuleNamespace
+	return r.CreateRuleGroupsStr(ctx, map[string]string{namespace: rg})[namespace]
+}
 
-	err := yaml.Unmarshal([]byte(rg), &rns)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal rule group: %w", err)
+// CreateRuleGroupsStr creates the rule groups of several namespaces from strings
+// Rule groups are created concurrently, every rule group being created even if others fail
+// It returns the error of each namespace whose rule groups couldn't all be created
+func (r *MimirClient) CreateRuleGroupsStr(ctx context.Context, namespaces map[string]string) map[string]error {
+	type namespacedGroup struct {
+		namespace string
+		group     rwrulefmt.RuleGroup
 	}
 
-	for _, group := range rns.Groups {
-		err := r.CreateRuleGroup(ctx, namespace, group)
+	failed := map[string]error{}
+
+	var groups []namespacedGroup
+	for namespace, rg := range namespaces {
+		parsed, err := ParseRuleGroups(rg)
 		if err != nil {
-			return err
+			failed[namespace] = err
+			continue
+		}
+
+		for _, group := range parsed {
+			groups = append(groups, namespacedGroup{namespace: namespace, group: group})
 		}
 	}
 
-	return nil
+	errs := forEach(r.concurrency, groups, func(g namespacedGroup) error {
+		return r.CreateRuleGroup(ctx, g.namespace, g.group)
+	})
+	for i, err := range errs {
+		if err != nil {
+			namespace := groups[i].namespace
+			failed[namespace] = stderrors.Join(failed[namespace], err)
+		}
+	}
+
+	return failed
 }
 
 // DeleteRuleGroup deletes a rule group
@@ -71,7 +105,7 @@ func (r *MimirClient) DeleteRuleGroup(ctx context.Context, namespace, groupName
 	escapedGroupName := url.PathEscape(groupName)
 	path := r.apiPath + "/" + escapedNamespace + "/" + escapedGroupName
 
-	res, err := r.doRequest(ctx, path, "DELETE", nil, -1)
+	res, err := r.doRequest(ctx, r.apiPath+groupRoute, path, "DELETE", nil, -1)
 	if err != nil {
 		return err
 	}
@@ -88,7 +122,7 @@ func (r *MimirClient) GetRuleGroup(ctx context.Context, namespace, groupName str
 	path := r.apiPath + "/" + escapedNamespace + "/" + escapedGroupName
 
 	fmt.Println(path)
-	res, err := r.doRequest(ctx, path, "GET", nil, -1)
+	res, err := r.doRequest(ctx, r.apiPath+groupRoute, path, "GET", nil, -1)
 	if err != nil {
 		return nil, err
 	}
@@ -115,12 +149,12 @@ func (r *MimirClient) GetRuleGroup(ctx context.Context, namespace, groupName str
 
 // ListRules retrieves a rule group
 func (r *MimirClient) ListRules(ctx context.Context, namespace string) (map[string][]rwrulefmt.RuleGroup, error) {
-	path := r.apiPath
+	path, route := r.apiPath, r.apiPath
 	if namespace != "" {
-		path = path + "/" + namespace
+		path, route = path+"/"+namespace, route+namespaceRoute
 	}
 
-	res, err := r.doRequest(ctx, path, "GET", nil, -1)
+	res, err := r.doRequest(ctx, route, path, "GET", nil, -1)
 	if err != nil {
 		return nil, err
 	}
@@ -167,12 +201,29 @@ func (r *MimirClient) ListRulesElement(ctx context.Context, namespace string) ([
 	return rules, nil
 }
 
+// DeleteNamespaces deletes several namespaces concurrently, every namespace being deleted even if others fail
+// It returns the error of each namespace that couldn't be deleted
+func (r *MimirClient) DeleteNamespaces(ctx context.Context, namespaces []string) map[string]error {
+	failed := map[string]error{}
+
+	errs := forEach(r.concurrency, namespaces, func(namespace string) error {
+		return r.DeleteNamespace(ctx, namespace)
+	})
+	for i, err := range errs {
+		if err != nil {
+			failed[namespaces[i]] = err
+		}
+	}
+
+	return failed
+}
+
 // DeleteNamespace delete all the rule groups in a namespace including the namespace itself
 func (r *MimirClient) DeleteNamespace(ctx context.Context, namespace string) error {
 	escapedNamespace := url.PathEscape(namespace)
 	path := r.apiPath + "/" + escapedNamespace
 
-	res, err := r.doRequest(ctx, path, "DELETE", nil, -1)
+	res, err := r.doRequest(ctx, r.apiPath+namespaceRoute, path, "DELETE", nil, -1)
 	if err != nil {
 		return err
 	}
@@ -181,3 +232,56 @@ func (r *MimirClient) DeleteNamespace(ctx context.Context, namespace string) err
 
 	return nil
 }
+
+// ParseRuleGroups parses the rule groups of a rule file
+func ParseRuleGroups(rg string) ([]rwrulefmt.RuleGroup, error) {
+	var rns rwrulefmt.RuleNamespace
+
+	err := yaml.Unmarshal([]byte(rg), &rns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule group: %w", err)
+	}
+
+	return rns.Groups, nil
+}
+
+// RuleGroupsDigest returns a digest of the content of rule groups, which is the same for rule groups
+// read from a rule file and returned by Mimir, whatever their order and formatting
+func RuleGroupsDigest(groups []rwrulefmt.RuleGroup) (string, error) {
+	type group struct {
+		Name      string                        `yaml:"name"`
+		Interval  model.Duration                `yaml:"interval,omitempty"`
+		Limit     int                           `yaml:"limit,omitempty"`
+		Rules     []rwrulefmt.Rule              `yaml:"rules"`
+		RWConfigs []rwrulefmt.RemoteWriteConfig `yaml:"remote_write,omitempty"`
+	}
+
+	// Rules are read from their YAML nodes, so that the digest doesn't depend on the style of their strings
+	normalized := make([]group, 0, len(groups))
+	for _, g := range groups {
+		rules := make([]rwrulefmt.Rule, 0, len(g.Rules))
+		for _, rule := range g.Rules {
+			rules = append(rules, rwrulefmt.Rule{
+				Record:        rule.Record.Value,
+				Alert:         rule.Alert.Value,
+				Expr:          rule.Expr.Value,
+				For:           rule.For,
+				KeepFiringFor: rule.KeepFiringFor,
+				Labels:        rule.Labels,
+				Annotations:   rule.Annotations,
+			})
+		}
+		normalized = append(normalized, group{Name: g.Name, Interval: g.Interval, Limit: g.Limit, Rules: rules, RWConfigs: g.RWConfigs})
+	}
+	sort.Slice(normalized, func(i, j int) bool {
+		return normalized[i].Name < normalized[j].Name
+	})
+
+	content, err := yaml.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
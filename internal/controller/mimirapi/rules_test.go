@@ -0,0 +1,128 @@
+package mimirapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRuleGroupsDigest(t *testing.T) {
+	digest := func(content string) string {
+		t.Helper()
+
+		groups, err := ParseRuleGroups(content)
+		if err != nil {
+			t.Fatalf("failed to parse rule groups: %v", err)
+		}
+		d, err := RuleGroupsDigest(groups)
+		if err != nil {
+			t.Fatalf("failed to compute digest: %v", err)
+		}
+		return d
+	}
+
+	reference := digest(`
+groups:
+  - name: a
+    rules:
+      - alert: HighErrorRate
+        expr: rate(errors_total[5m]) > 1
+        for: 1h
+        labels:
+          severity: critical
+          team: sre
+  - name: b
+    rules:
+      - record: job:errors:rate5m
+        expr: sum by (job) (rate(errors_total[5m]))
+`)
+
+	// Same groups in another order, with another formatting
+	equivalent := digest(`
+groups:
+- name: b
+  rules:
+  - record: "job:errors:rate5m"
+    expr: 'sum by (job) (rate(errors_total[5m]))'
+- name: a
+  rules:
+  - alert: HighErrorRate
+    expr: >-
+      rate(errors_total[5m]) > 1
+    for: 60m
+    labels:
+      team: sre
+      severity: critical
+`)
+	if equivalent != reference {
+		t.Errorf("expected equivalent rule groups to have the same digest")
+	}
+
+	changed := digest(`
+groups:
+  - name: a
+    rules:
+      - alert: HighErrorRate
+        expr: rate(errors_total[5m]) > 2
+        for: 1h
+        labels:
+          severity: critical
+          team: sre
+  - name: b
+    rules:
+      - record: job:errors:rate5m
+        expr: sum by (job) (rate(errors_total[5m]))
+`)
+	if changed == reference {
+		t.Errorf("expected different rule groups to have different digests")
+	}
+}
+
+func TestCreateRuleGroupsStr(t *testing.T) {
+	var inFlight, maxInFlight, calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := maxInFlight.Load()
+			if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if strings.HasSuffix(r.URL.Path, "/broken") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(Config{Address: server.URL, ID: "tenant", Concurrency: 3})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	namespaces := map[string]string{"broken": "groups:\n  - name: g\n    rules: []\n"}
+	for i := 0; i < 4; i++ {
+		namespaces[fmt.Sprintf("ns%d", i)] = "groups:\n  - name: a\n    rules: []\n  - name: b\n    rules: []\n"
+	}
+
+	failed := client.CreateRuleGroupsStr(context.Background(), namespaces)
+	if len(failed) != 1 || failed["broken"] == nil {
+		t.Errorf("expected only the broken namespace to fail, got %v", failed)
+	}
+	if calls.Load() != 9 {
+		t.Errorf("expected every rule group to be created, got %d requests", calls.Load())
+	}
+	if maxInFlight.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent requests, got %d", maxInFlight.Load())
+	}
+}
@@ -0,0 +1,232 @@
+package mimirapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/template"
+	"gopkg.in/yaml.v3"
+
+	rwmodel "github.com/AmiditeX/mimir-operator/internal/controller/mimirapi/rwrulefmt/model"
+)
+
+// ErrInvalidRules is returned when rules are found invalid before being sent to Mimir
+var ErrInvalidRules = errors.New("invalid rules")
+
+// validationNamespace is a namespace of rules as read by the Mimir ruler
+type validationNamespace struct {
+	Groups []validationGroup `yaml:"groups"`
+}
+
+type validationGroup struct {
+	Name  string           `yaml:"name"`
+	Rules []validationRule `yaml:"rules"`
+}
+
+// validationRule is a rwrulefmt.RuleNode whose durations are kept as strings, so that they are validated along with
+// the other fields instead of failing the decoding of the whole namespace
+type validationRule struct {
+	Record        string            `yaml:"record"`
+	Alert         string            `yaml:"alert"`
+	Expr          string            `yaml:"expr"`
+	For           string            `yaml:"for"`
+	KeepFiringFor string            `yaml:"keep_firing_for"`
+	Labels        map[string]string `yaml:"labels"`
+	Annotations   map[string]string `yaml:"annotations"`
+}
+
+// RuleLocator returns where the group and the rule at the given indexes of a namespace are defined, rule being -1
+// for the group itself
+// It lets the errors point at the source of the rules rather than at the namespace sent to Mimir
+type RuleLocator func(group, rule int) string
+
+// namespaceLocator locates the groups and the rules in the namespace itself
+func namespaceLocator(group, rule int) string {
+	if rule < 0 {
+		return fmt.Sprintf("groups[%d]", group)
+	}
+	return fmt.Sprintf("groups[%d].rules[%d]", group, rule)
+}
+
+// ValidateRuleGroups checks the rules of a namespace the way the Mimir ruler does, so that invalid rules are found
+// before anything is sent to Mimir
+// Expressions are parsed with the PromQL parser, and durations, label names and templates are checked along with
+// the names of groups and rules, which must not be repeated. The errors point at the group and the rule where locate
+// finds them, or in rg if it is nil, and at their line and column in the expressions.
+func ValidateRuleGroups(rg string, locate RuleLocator) error {
+	if locate == nil {
+		locate = namespaceLocator
+	}
+
+	var rns validationNamespace
+	if err := yaml.Unmarshal([]byte(rg), &rns); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRules, err)
+	}
+
+	var errs []error
+	groups := map[string]int{}
+	for g, group := range rns.Groups {
+		if group.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: group name must be set", locate(g, -1)))
+			continue
+		}
+		if first, ok := groups[group.Name]; ok {
+			errs = append(errs, fmt.Errorf("group %q (%s): group name is repeated, first defined at %s", group.Name, locate(g, -1), locate(first, -1)))
+			continue
+		}
+		groups[group.Name] = g
+
+		rules := map[string]int{}
+		for i, rule := range group.Rules {
+			for _, err := range rule.validate() {
+				errs = append(errs, fmt.Errorf("group %q, rule %q (%s): %w", group.Name, rule.name(), locate(g, i), err))
+			}
+
+			// Rules with the same name and labels produce the same series
+			key := rule.name() + "\xff" + labelsKey(rule.Labels)
+			if first, ok := rules[key]; ok {
+				errs = append(errs, fmt.Errorf("group %q, rule %q (%s): rule is repeated with the same labels, first defined at %s", group.Name, rule.name(), locate(g, i), locate(g, first)))
+				continue
+			}
+			rules[key] = i
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidRules, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// name returns the name of a rule
+func (r *validationRule) name() string {
+	if r.Alert != "" {
+		return r.Alert
+	}
+	return r.Record
+}
+
+// validate returns the errors found in a rule
+func (r *validationRule) validate() []error {
+	var errs []error
+
+	if r.Record != "" && r.Alert != "" {
+		errs = append(errs, errors.New("only one of 'record' and 'alert' must be set"))
+	}
+	if r.Record == "" && r.Alert == "" {
+		errs = append(errs, errors.New("one of 'record' or 'alert' must be set"))
+	}
+
+	if r.Expr == "" {
+		errs = append(errs, errors.New("field 'expr' must be set"))
+	} else if err := parseExpr(r.Expr); err != nil {
+		errs = append(errs, fmt.Errorf("could not parse expression: %w", err))
+	}
+
+	for _, duration := range []struct {
+		field string
+		value string
+	}{{"for", r.For}, {"keep_firing_for", r.KeepFiringFor}} {
+		if duration.value == "" {
+			continue
+		}
+		if _, err := rwmodel.ParseDuration(duration.value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid '%s' duration: %w", duration.field, err))
+		} else if r.Record != "" {
+			errs = append(errs, fmt.Errorf("invalid field '%s' in recording rule", duration.field))
+		}
+	}
+
+	if r.Record != "" {
+		if !model.IsValidMetricName(model.LabelValue(r.Record)) {
+			errs = append(errs, fmt.Errorf("invalid recording rule name: %s", r.Record))
+		}
+		if len(r.Annotations) > 0 {
+			errs = append(errs, errors.New("invalid field 'annotations' in recording rule"))
+		}
+	}
+
+	for _, name := range sortedKeys(r.Labels) {
+		if !model.LabelName(name).IsValid() || name == model.MetricNameLabel {
+			errs = append(errs, fmt.Errorf("invalid label name: %s", name))
+		}
+		if !utf8.ValidString(r.Labels[name]) {
+			errs = append(errs, fmt.Errorf("invalid value of label %q: %s", name, r.Labels[name]))
+		}
+	}
+
+	for _, name := range sortedKeys(r.Annotations) {
+		if !model.LabelName(name).IsValid() {
+			errs = append(errs, fmt.Errorf("invalid annotation name: %s", name))
+		}
+	}
+
+	// The labels and annotations of alerts are templates, expanded when the alerts fire
+	if r.Alert != "" {
+		for _, name := range sortedKeys(r.Labels) {
+			if err := parseTemplate(r.Alert, r.Labels[name]); err != nil {
+				errs = append(errs, fmt.Errorf("invalid template in label %q: %w", name, err))
+			}
+		}
+		for _, name := range sortedKeys(r.Annotations) {
+			if err := parseTemplate(r.Alert, r.Annotations[name]); err != nil {
+				errs = append(errs, fmt.Errorf("invalid template in annotation %q: %w", name, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// parseExpr parses the expression of a rule, its errors pointing at their line and column in the expression
+func parseExpr(expr string) error {
+	_, err := parser.ParseExpr(expr)
+
+	var parseErrs parser.ParseErrors
+	if !errors.As(err, &parseErrs) || len(parseErrs) == 0 {
+		return err
+	}
+
+	// Only the first error is reported, as the parser does
+	start := min(max(int(parseErrs[0].PositionRange.Start), 0), len(expr))
+	line := strings.Count(expr[:start], "\n") + 1
+	column := utf8.RuneCountInString(expr[strings.LastIndex(expr[:start], "\n")+1:start]) + 1
+
+	return fmt.Errorf("line %d, column %d: %w", line, column, parseErrs[0].Err)
+}
+
+// parseTemplate parses a template of an alert with the variables and functions the ruler provides
+func parseTemplate(alert, text string) error {
+	defs := "{{$labels := .Labels}}{{$externalLabels := .ExternalLabels}}{{$externalURL := .ExternalURL}}{{$value := .Value}}"
+	data := template.AlertTemplateData(map[string]string{}, map[string]string{}, "", 0)
+
+	expander := template.NewTemplateExpander(context.Background(), defs+text, "__alert_"+alert, data, model.TimeFromUnixNano(time.Now().UnixNano()), nil, nil, nil)
+	return expander.ParseTest()
+}
+
+// labelsKey returns a key identifying a set of labels
+func labelsKey(labels map[string]string) string {
+	var b strings.Builder
+	for _, name := range sortedKeys(labels) {
+		b.WriteString(name + "\xff" + labels[name] + "\xff")
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
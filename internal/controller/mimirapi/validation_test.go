@@ -0,0 +1,132 @@
+package mimirapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateRuleGroups(t *testing.T) {
+	valid := `
+groups:
+  - name: a
+    rules:
+      - alert: HighErrorRate
+        expr: rate(errors_total[5m]) > 1
+        for: 1h
+        labels:
+          severity: critical
+        annotations:
+          summary: "{{ $labels.job }} has {{ $value | humanize }} errors"
+      - record: job:errors:rate5m
+        expr: sum by (job) (rate(errors_total[5m]))
+`
+	if err := ValidateRuleGroups(valid, nil); err != nil {
+		t.Errorf("expected valid rules, got %v", err)
+	}
+
+	// Rules of a group may share a name when their labels differ
+	distinct := `
+groups:
+  - name: a
+    rules:
+      - record: job:up
+        expr: sum by (job) (up)
+        labels:
+          aggregation: sum
+      - record: job:up
+        expr: max by (job) (up)
+        labels:
+          aggregation: max
+`
+	if err := ValidateRuleGroups(distinct, nil); err != nil {
+		t.Errorf("expected rules with distinct labels to be valid, got %v", err)
+	}
+
+	tests := map[string]struct {
+		content string
+		errors  []string
+	}{
+		"invalid expression": {
+			content: `
+groups:
+  - name: a
+    rules:
+      - alert: HighErrorRate
+        expr: rate(errors_total[5m] > 1
+`,
+			errors: []string{`group "a", rule "HighErrorRate" (groups[0].rules[0]): could not parse expression: line 1, column 26`},
+		},
+		"invalid duration": {
+			content: `
+groups:
+  - name: a
+    rules:
+      - alert: HighErrorRate
+        expr: up == 0
+        for: 5x
+`,
+			errors: []string{`group "a", rule "HighErrorRate" (groups[0].rules[0]): invalid 'for' duration`},
+		},
+		"invalid multi-line expression": {
+			content: `
+groups:
+  - name: a
+    rules:
+      - record: job:errors
+        expr: |
+          sum by (job) (
+            rate(errors_total[5m]) +
+          )
+`,
+			errors: []string{`group "a", rule "job:errors" (groups[0].rules[0]): could not parse expression: line 3, column 1`},
+		},
+		"invalid label name and template": {
+			content: `
+groups:
+  - name: a
+    rules:
+      - alert: HighErrorRate
+        expr: up == 0
+        labels:
+          team-name: sre
+        annotations:
+          summary: "{{ $labels.job "
+`,
+			errors: []string{"invalid label name: team-name", `invalid template in annotation "summary"`},
+		},
+		"repeated names": {
+			content: `
+groups:
+  - name: a
+    rules:
+      - record: job:up
+        expr: sum by (job) (up)
+      - record: job:up
+        expr: max by (job) (up)
+  - name: a
+    rules:
+      - record: job:down
+        expr: sum by (job) (up == 0)
+`,
+			errors: []string{
+				`group "a", rule "job:up" (groups[0].rules[1]): rule is repeated with the same labels, first defined at groups[0].rules[0]`,
+				`group "a" (groups[1]): group name is repeated, first defined at groups[0]`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateRuleGroups(test.content, nil)
+			if !errors.Is(err, ErrInvalidRules) {
+				t.Fatalf("expected invalid rules, got %v", err)
+			}
+			for _, expected := range test.errors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error to contain %q, got %q", expected, err.Error())
+				}
+			}
+		})
+	}
+}
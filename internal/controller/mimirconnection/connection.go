@@ -0,0 +1,276 @@
+package mimirconnection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+// Target describes the tenant of a remote Mimir instance a resource is synchronized to
+// The remote instance is either defined inline (URL and Auth) or through a ConnectionRef
+type Target struct {
+	// Kind of the resource, used to check cross-namespace Secret references against MimirReferenceGrants
+	Kind string
+
+	// ID of the tenant in Mimir
+	ID string
+
+	// URL and Auth are the inline connection settings of the resource
+	URL  string
+	Auth *domain.Auth
+
+	// ConnectionRef references a MimirConnection or a ClusterMimirConnection
+	ConnectionRef *domain.ConnectionReference
+
+	// Headers of the resource, added to the headers of the connection
+	Headers []domain.Header
+
+	// Namespace of the resource, used to resolve namespaced connections and Secrets
+	Namespace string
+}
+
+// Resolver builds Mimir clients from the connection settings of a resource
+type Resolver struct {
+	Client client.Client
+
+	// Pool shares HTTP clients between the Mimir clients created with the same settings
+	Pool *mimirapi.Pool
+
+	// APIReader reads the Secrets and ConfigMaps referenced by the connection settings from the API server,
+	// as the cache of the manager doesn't hold them. Client is used if it is nil
+	APIReader client.Reader
+
+	// References caches the metadata of the Secrets and ConfigMaps, so that the resources referencing them
+	// are synchronized again when they change. Secrets and ConfigMaps are not watched if it is nil
+	References cache.Cache
+
+	// ClusterResourceNamespace is the namespace in which the Secrets referenced
+	// by ClusterMimirConnections are looked up
+	ClusterResourceNamespace string
+}
+
+// NewMimirClient returns a client for the tenant described by the target
+func (r *Resolver) NewMimirClient(ctx context.Context, t Target) (*mimirapi.MimirClient, error) {
+	spec, referrer, err := r.resolveSpec(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := utils.ExtractHeaders(ctx, r.reader(), t.Headers, utils.Referrer{Kind: t.Kind, Namespace: t.Namespace})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.newClient(ctx, spec, referrer, RefIndexValue(t.Namespace, t.ConnectionRef), t.ID, headers)
+}
+
+// reader returns the reader of the Secrets and ConfigMaps referenced by the connection settings
+func (r *Resolver) reader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+
+	return utils.ReferenceReader(r.Client, r.APIReader)
+}
+
+// resolveSpec returns the connection settings of a target along with the resource referencing
+// the Secrets of those settings
+func (r *Resolver) resolveSpec(ctx context.Context, t Target) (*domain.MimirConnectionSpec, utils.Referrer, error) {
+	if t.ConnectionRef == nil {
+		if t.URL == "" {
+			return nil, utils.Referrer{}, errors.New("either url or connectionRef must be set")
+		}
+
+		return &domain.MimirConnectionSpec{URL: t.URL, Auth: t.Auth}, utils.Referrer{Kind: t.Kind, Namespace: t.Namespace}, nil
+	}
+
+	if t.URL != "" || t.Auth != nil {
+		return nil, utils.Referrer{}, errors.New("url and auth can't be set alongside connectionRef")
+	}
+
+	switch t.ConnectionRef.Kind {
+	case "", domain.MimirConnectionKind:
+		conn := &domain.MimirConnection{}
+		key := client.ObjectKey{Namespace: t.Namespace, Name: t.ConnectionRef.Name}
+		if err := r.Client.Get(ctx, key, conn); err != nil {
+			return nil, utils.Referrer{}, fmt.Errorf("failed to retrieve MimirConnection %s: %w", key, err)
+		}
+
+		return &conn.Spec, utils.Referrer{Kind: domain.MimirConnectionKind, Namespace: conn.Namespace}, nil
+	case domain.ClusterMimirConnectionKind:
+		conn := &domain.ClusterMimirConnection{}
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: t.ConnectionRef.Name}, conn); err != nil {
+			return nil, utils.Referrer{}, fmt.Errorf("failed to retrieve ClusterMimirConnection %s: %w", t.ConnectionRef.Name, err)
+		}
+
+		return &conn.Spec, r.clusterReferrer(), nil
+	default:
+		return nil, utils.Referrer{}, fmt.Errorf("unsupported connection kind '%s'", t.ConnectionRef.Kind)
+	}
+}
+
+// clusterReferrer returns the referrer of the Secrets of ClusterMimirConnections, which are looked up in the cluster resource namespace
+func (r *Resolver) clusterReferrer() utils.Referrer {
+	return utils.Referrer{Kind: domain.ClusterMimirConnectionKind, Namespace: r.ClusterResourceNamespace}
+}
+
+// newClient creates a Mimir client for a tenant using the settings of a connection
+// extraHeaders are added to the headers of the connection, overriding them if they have the same name
+// owner identifies the connection in the Pool, it is empty for inline settings
+func (r *Resolver) newClient(ctx context.Context, spec *domain.MimirConnectionSpec, referrer utils.Referrer, owner, id string, extraHeaders map[string]string) (*mimirapi.MimirClient, error) {
+	cfg, err := r.clientConfig(ctx, spec, referrer, id, extraHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.clientFor(owner, cfg)
+}
+
+// clientConfig returns the configuration of a Mimir client for a tenant using the settings of a connection,
+// reading the Secrets and ConfigMaps they reference
+func (r *Resolver) clientConfig(ctx context.Context, spec *domain.MimirConnectionSpec, referrer utils.Referrer, id string, extraHeaders map[string]string) (mimirapi.Config, error) {
+	auth, err := utils.ExtractAuth(ctx, r.reader(), spec.Auth, referrer)
+	if err != nil {
+		return mimirapi.Config{}, fmt.Errorf("%w: %w", utils.ErrAuthSettings, err)
+	}
+
+	headers, err := utils.ExtractHeaders(ctx, r.reader(), spec.Headers, referrer)
+	if err != nil {
+		return mimirapi.Config{}, err
+	}
+	for name, value := range extraHeaders {
+		headers[name] = value
+	}
+
+	var oauth2 mimirapi.OAuth2Config
+	if auth.OAuth2 != nil {
+		oauth2 = mimirapi.OAuth2Config{
+			TokenURL:       auth.OAuth2.TokenURL,
+			ClientID:       auth.OAuth2.ClientID,
+			ClientSecret:   auth.OAuth2.ClientSecret,
+			Scopes:         auth.OAuth2.Scopes,
+			EndpointParams: auth.OAuth2.EndpointParams,
+			TLS:            tlsConfig(auth.OAuth2.TLS),
+		}
+	}
+
+	cfg := mimirapi.Config{
+		User:      auth.Username,
+		Key:       auth.Key,
+		AuthToken: auth.Token,
+		OAuth2:    oauth2,
+		Address:   spec.URL,
+		ID:        id,
+		TLS:       tlsConfig(auth.TLS),
+		Headers:   headers,
+		ProxyURL:  spec.ProxyURL,
+		NoProxy:   spec.NoProxy,
+		Retry:     retryConfig(spec.Retry),
+		Timeouts:  timeouts(spec.Timeouts),
+	}
+
+	return cfg, nil
+}
+
+// clientFor returns a Mimir client for a configuration, sharing its HTTP client through the Pool if there is one
+func (r *Resolver) clientFor(owner string, cfg mimirapi.Config) (*mimirapi.MimirClient, error) {
+	var (
+		c   *mimirapi.MimirClient
+		err error
+	)
+	if r.Pool != nil {
+		c, err = r.Pool.Get(owner, cfg)
+	} else {
+		c, err = mimirapi.New(cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mimir client: %w", err)
+	}
+
+	return c, nil
+}
+
+// tlsConfig returns the TLS settings extracted from a CRD as the ones of a Mimir client
+func tlsConfig(t utils.TLS) mimirapi.TLSConfig {
+	return mimirapi.TLSConfig{
+		CA:                 t.CA,
+		Cert:               t.Cert,
+		Key:                t.Key,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+}
+
+// retryConfig returns the retry policy of a connection, using the default policy for the settings it doesn't set
+func retryConfig(policy *domain.RetryPolicy) mimirapi.RetryConfig {
+	cfg := mimirapi.DefaultRetryConfig
+	if policy == nil {
+		return cfg
+	}
+
+	if policy.MaxRetries != nil {
+		cfg.MaxRetries = int(*policy.MaxRetries)
+	}
+	if policy.MinBackoff != nil {
+		cfg.MinBackoff = policy.MinBackoff.Duration
+	}
+	if policy.MaxBackoff != nil {
+		cfg.MaxBackoff = policy.MaxBackoff.Duration
+	}
+
+	return cfg
+}
+
+// timeouts returns the timeouts of a connection, the ones left empty being set by the Pool
+func timeouts(t *domain.Timeouts) mimirapi.Timeouts {
+	var result mimirapi.Timeouts
+	if t == nil {
+		return result
+	}
+
+	if t.Dial != nil {
+		result.Dial = t.Dial.Duration
+	}
+	if t.TLSHandshake != nil {
+		result.TLSHandshake = t.TLSHandshake.Duration
+	}
+	if t.Request != nil {
+		result.Request = t.Request.Duration
+	}
+
+	return result
+}
+
+// RequeueOnDegraded returns the result requeuing a resource once requests can be sent again to Mimir
+// if err was caused by an open circuit breaker, or an empty result otherwise
+func RequeueOnDegraded(err error) ctrl.Result {
+	if delay, degraded := mimirapi.CircuitOpenDelay(err); degraded {
+		return ctrl.Result{RequeueAfter: delay}
+	}
+
+	return ctrl.Result{}
+}
+
+// resyncJitter is the maximum fraction of the resync interval added to it, so that resources synchronized
+// at the same time, such as at the startup of the operator, don't keep hitting Mimir together
+const resyncJitter = 0.1
+
+// RequeueForResync returns the result requeuing a resource to synchronize it again after interval, with jitter
+// It returns an empty result if interval is 0
+func RequeueForResync(interval time.Duration) ctrl.Result {
+	if interval <= 0 {
+		return ctrl.Result{}
+	}
+
+	return ctrl.Result{RequeueAfter: interval + time.Duration(rand.Float64()*resyncJitter*float64(interval))}
+}
@@ -0,0 +1,224 @@
+package mimirconnection
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// clusterResourceNamespace is the namespace of the Secrets referenced by ClusterMimirConnections in the tests
+const clusterResourceNamespace = "operator"
+
+// newTestMimir returns the URL of a Mimir instance recording the headers of the last request it received
+func newTestMimir(t *testing.T) (string, func() http.Header) {
+	var (
+		mu     sync.Mutex
+		header http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		header = r.Header.Clone()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, func() http.Header {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return header
+	}
+}
+
+// newTestResolver returns a resolver reading the given objects with a fake client
+func newTestResolver(t *testing.T, objs ...client.Object) *Resolver {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, domain.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatalf("failed to build scheme: %v", err)
+		}
+	}
+
+	return &Resolver{
+		Client:                   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		ClusterResourceNamespace: clusterResourceNamespace,
+	}
+}
+
+// newTokenSecret returns a Secret named token holding a token under its default key
+func newTokenSecret(namespace, token string) *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "token"}, Data: map[string][]byte{"token": []byte(token)}}
+}
+
+// newGrant returns a MimirReferenceGrant allowing the resources described by from to reference the Secret named token
+func newGrant(namespace string, from domain.ReferenceGrantFrom) *domain.MimirReferenceGrant {
+	return &domain.MimirReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "grant"},
+		Spec: domain.MimirReferenceGrantSpec{
+			From: []domain.ReferenceGrantFrom{from},
+			To:   []domain.ReferenceGrantTo{{Kind: domain.SecretKind, Name: "token"}},
+		},
+	}
+}
+
+func TestNewMimirClient(t *testing.T) {
+	url, lastHeader := newTestMimir(t)
+	tokenAuth := func(namespace string) *domain.Auth {
+		return &domain.Auth{TokenSecretRef: &domain.SecretKeySelector{Name: "token", Namespace: namespace}}
+	}
+
+	tests := map[string]struct {
+		objs    []client.Object
+		target  Target
+		headers map[string]string
+		invalid bool
+	}{
+		"inline": {
+			target:  Target{URL: url, Auth: &domain.Auth{Token: "inline"}},
+			headers: map[string]string{"Authorization": "Bearer inline"},
+		},
+		"connection": {
+			objs: []client.Object{
+				&domain.MimirConnection{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "mimir"},
+					Spec:       domain.MimirConnectionSpec{URL: url, Auth: tokenAuth("")},
+				},
+				newTokenSecret("team", "connection"),
+			},
+			target:  Target{ConnectionRef: &domain.ConnectionReference{Name: "mimir"}},
+			headers: map[string]string{"Authorization": "Bearer connection"},
+		},
+		"cluster connection": {
+			objs: []client.Object{
+				&domain.ClusterMimirConnection{
+					ObjectMeta: metav1.ObjectMeta{Name: "mimir"},
+					Spec:       domain.MimirConnectionSpec{URL: url, Auth: tokenAuth("")},
+				},
+				newTokenSecret(clusterResourceNamespace, "cluster"),
+				newTokenSecret("team", "team"),
+			},
+			target:  Target{ConnectionRef: &domain.ConnectionReference{Kind: domain.ClusterMimirConnectionKind, Name: "mimir"}},
+			headers: map[string]string{"Authorization": "Bearer cluster"},
+		},
+		"headers override the connection": {
+			objs: []client.Object{
+				&domain.MimirConnection{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "mimir"},
+					Spec: domain.MimirConnectionSpec{URL: url, Headers: []domain.Header{
+						{Name: "X-Shared", Value: "connection"},
+						{Name: "X-Connection", Value: "connection"},
+					}},
+				},
+			},
+			target: Target{
+				ConnectionRef: &domain.ConnectionReference{Name: "mimir"},
+				Headers:       []domain.Header{{Name: "X-Shared", Value: "resource"}},
+			},
+			headers: map[string]string{"X-Shared": "resource", "X-Connection": "connection"},
+		},
+		"headers read from secrets": {
+			objs: []client.Object{
+				&domain.MimirConnection{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "mimir"},
+					Spec: domain.MimirConnectionSpec{URL: url, Headers: []domain.Header{
+						{Name: "X-Shared", SecretKeyRef: &domain.SecretKeySelector{Name: "token", Key: "token"}},
+						{Name: "X-Connection", Value: "value", SecretKeyRef: &domain.SecretKeySelector{Name: "token", Key: "token"}},
+					}},
+				},
+				newTokenSecret("team", "secret"),
+			},
+			target: Target{
+				ConnectionRef: &domain.ConnectionReference{Name: "mimir"},
+				Headers:       []domain.Header{{Name: "X-Shared", Value: "resource"}},
+			},
+			headers: map[string]string{"X-Shared": "resource", "X-Connection": "secret"},
+		},
+		"cross-namespace secret without grant": {
+			objs:    []client.Object{newTokenSecret("shared", "shared")},
+			target:  Target{URL: url, Auth: tokenAuth("shared")},
+			invalid: true,
+		},
+		"cross-namespace secret granted to another namespace": {
+			objs: []client.Object{
+				newTokenSecret("shared", "shared"),
+				newGrant("shared", domain.ReferenceGrantFrom{Kind: domain.MimirRulesKind, Namespace: "other"}),
+			},
+			target:  Target{URL: url, Auth: tokenAuth("shared")},
+			invalid: true,
+		},
+		"cross-namespace secret with grant": {
+			objs: []client.Object{
+				newTokenSecret("shared", "shared"),
+				newGrant("shared", domain.ReferenceGrantFrom{Kind: domain.MimirRulesKind, Namespace: "team"}),
+			},
+			target:  Target{URL: url, Auth: tokenAuth("shared")},
+			headers: map[string]string{"Authorization": "Bearer shared"},
+		},
+		"url and connection": {
+			objs: []client.Object{
+				&domain.MimirConnection{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "mimir"}, Spec: domain.MimirConnectionSpec{URL: url}},
+			},
+			target:  Target{URL: url, ConnectionRef: &domain.ConnectionReference{Name: "mimir"}},
+			invalid: true,
+		},
+		"neither url nor connection": {
+			target:  Target{},
+			invalid: true,
+		},
+		"missing connection": {
+			target:  Target{ConnectionRef: &domain.ConnectionReference{Name: "mimir"}},
+			invalid: true,
+		},
+		"unsupported connection kind": {
+			target:  Target{ConnectionRef: &domain.ConnectionReference{Kind: "Connection", Name: "mimir"}},
+			invalid: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := newTestResolver(t, test.objs...)
+			target := test.target
+			target.Kind = domain.MimirRulesKind
+			target.Namespace = "team"
+			target.ID = "tenant"
+
+			mc, err := r.NewMimirClient(context.Background(), target)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, err := mc.ListRules(context.Background(), ""); err != nil {
+				t.Fatalf("failed to reach Mimir: %v", err)
+			}
+			header := lastHeader()
+			if got := header.Get("X-Scope-OrgID"); got != "tenant" {
+				t.Errorf("expected the tenant to be sent, got %q", got)
+			}
+			for name, value := range test.headers {
+				if got := header.Values(name); len(got) != 1 || got[0] != value {
+					t.Errorf("expected header %s to be %q, got %v", name, value, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package mimirconnection
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+)
+
+// ErrTargetMoved is returned when the settings of a previous target now point to another Mimir instance or tenant,
+// for instance because the URL of the connection it references changed
+var ErrTargetMoved = errors.New("previous target now resolves to another Mimir instance or tenant, " +
+	"its content can be left as is by setting the Orphan deletion policy")
+
+// ErrPreviousTargetUnreachable is returned when the settings used to reach a previous target are gone, because
+// the connection it references was deleted or the inline settings of the resource changed along with its URL
+var ErrPreviousTargetUnreachable = errors.New("the settings used to reach the previous target are gone")
+
+// Reference returns the reference of the tenant of a target, recorded in the status of the resources synchronized to it
+// It only identifies the tenant, use Resolver.Reference to also record the fingerprint of the settings used to reach it
+func (t Target) Reference() *domain.TargetReference {
+	return &domain.TargetReference{ID: t.ID, URL: t.URL, ConnectionRef: t.ConnectionRef}
+}
+
+// Reference returns the reference of the tenant of a target along with the fingerprint of the settings used to reach it
+func (r *Resolver) Reference(ctx context.Context, t Target) (*domain.TargetReference, error) {
+	spec, _, err := r.resolveSpec(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := t.Reference()
+	ref.Fingerprint = fingerprint(spec, t.Headers, t.ID)
+
+	return ref, nil
+}
+
+// fingerprint returns a digest of the settings used to reach a tenant, which doesn't reveal their credentials
+// Inline credentials and the values of headers are left out, the references to the Secrets holding them are kept
+func fingerprint(spec *domain.MimirConnectionSpec, headers []domain.Header, id string) string {
+	auth := spec.Auth.DeepCopy()
+	if auth != nil {
+		auth.Key = redact(auth.Key)
+		auth.Token = redact(auth.Token)
+	}
+
+	names := make([]domain.Header, 0, len(headers))
+	for _, header := range headers {
+		names = append(names, domain.Header{Name: header.Name, Value: redact(header.Value), SecretKeyRef: header.SecretKeyRef})
+	}
+
+	// Marshalling plain structures doesn't fail
+	data, _ := json.Marshal(struct {
+		Target  string          `json:"target"`
+		Auth    *domain.Auth    `json:"auth,omitempty"`
+		Headers []domain.Header `json:"headers,omitempty"`
+	}{mimirapi.NormalizeTarget(spec.URL + "|" + id), auth, names})
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// redact replaces a credential by a marker telling whether it is set
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "redacted"
+}
+
+// NewPreviousClient returns a client for a tenant a resource targeted before current
+// The previous target is reached with the current settings of the connection it references, or with the current
+// inline authentication settings and headers of the resource if it was set inline. Those are only used if the
+// fingerprint recorded along with the previous target still matches them, so that credentials changed along with
+// the URL are never sent to the previous Mimir instance.
+func (r *Resolver) NewPreviousClient(ctx context.Context, current Target, previous domain.PreviousTarget) (*mimirapi.MimirClient, error) {
+	t := current
+	t.ID = previous.ID
+	t.URL = previous.URL
+	t.ConnectionRef = previous.ConnectionRef
+	if t.ConnectionRef != nil {
+		t.Auth = nil
+	}
+
+	spec, _, err := r.resolveSpec(ctx, t)
+	if apierrors.IsNotFound(err) {
+		return nil, ErrPreviousTargetUnreachable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Never delete the content of a tenant the resource didn't synchronize to
+	if mimirapi.NormalizeTarget(spec.URL+"|"+t.ID) != mimirapi.NormalizeTarget(previous.Target) {
+		return nil, ErrTargetMoved
+	}
+	// Targets recorded by older versions of the operator have no fingerprint
+	if previous.ConnectionRef == nil && previous.Fingerprint != "" && fingerprint(spec, t.Headers, t.ID) != previous.Fingerprint {
+		return nil, ErrPreviousTargetUnreachable
+	}
+
+	return r.NewMimirClient(ctx, t)
+}
@@ -0,0 +1,123 @@
+package mimirconnection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestNewPreviousClient(t *testing.T) {
+	url, lastHeader := newTestMimir(t)
+	connection := func(url string) *domain.MimirConnection {
+		return &domain.MimirConnection{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "mimir"},
+			Spec:       domain.MimirConnectionSpec{URL: url, Auth: &domain.Auth{Token: "connection"}},
+		}
+	}
+
+	tests := map[string]struct {
+		objs []client.Object
+		// recorded is the target the resource was synchronized to, and current the one it targets now
+		recorded Target
+		current  Target
+		// movedTo is the URL of the connection when the previous target is cleaned up, if it changed
+		movedTo string
+		token   string
+		err     error
+	}{
+		"inline": {
+			recorded: Target{URL: url, Auth: &domain.Auth{Token: "inline"}},
+			current:  Target{URL: "http://next.example", Auth: &domain.Auth{Token: "inline"}},
+			token:    "inline",
+		},
+		"inline credentials changed": {
+			recorded: Target{URL: url, Auth: &domain.Auth{Token: "inline"}},
+			current:  Target{URL: "http://next.example", Auth: &domain.Auth{TokenSecretRef: &domain.SecretKeySelector{Name: "token"}}},
+			err:      ErrPreviousTargetUnreachable,
+		},
+		"inline to connection": {
+			objs:     []client.Object{connection("http://next.example")},
+			recorded: Target{URL: url, Auth: &domain.Auth{Token: "inline"}},
+			current:  Target{ConnectionRef: &domain.ConnectionReference{Name: "mimir"}},
+			err:      ErrPreviousTargetUnreachable,
+		},
+		"connection": {
+			objs:     []client.Object{connection(url)},
+			recorded: Target{ConnectionRef: &domain.ConnectionReference{Name: "mimir"}},
+			current:  Target{URL: "http://next.example"},
+			token:    "connection",
+		},
+		"connection deleted": {
+			recorded: Target{ConnectionRef: &domain.ConnectionReference{Name: "mimir"}},
+			current:  Target{URL: "http://next.example"},
+			err:      ErrPreviousTargetUnreachable,
+		},
+		"connection moved": {
+			objs:     []client.Object{connection(url)},
+			recorded: Target{ConnectionRef: &domain.ConnectionReference{Name: "mimir"}},
+			current:  Target{URL: "http://next.example"},
+			movedTo:  "http://other.example",
+			err:      ErrTargetMoved,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := newTestResolver(t, test.objs...)
+			ctx := context.Background()
+			for _, target := range []*Target{&test.recorded, &test.current} {
+				target.Kind = domain.MimirRulesKind
+				target.Namespace = "team"
+				target.ID = "tenant"
+			}
+
+			ref, err := r.Reference(ctx, test.recorded)
+			if err != nil && !errors.Is(test.err, ErrPreviousTargetUnreachable) {
+				t.Fatalf("failed to reference the target: %v", err)
+			}
+			if ref == nil {
+				ref = test.recorded.Reference()
+			}
+			status, _ := json.Marshal(ref)
+			if strings.Contains(string(status), "inline") || strings.Contains(string(status), `"connection"`) {
+				t.Errorf("expected the credentials to be left out of the reference, got %s", status)
+			}
+			if test.movedTo != "" {
+				conn := &domain.MimirConnection{}
+				if err := r.Client.Get(ctx, client.ObjectKey{Namespace: "team", Name: "mimir"}, conn); err != nil {
+					t.Fatalf("failed to get the connection: %v", err)
+				}
+				conn.Spec.URL = test.movedTo
+				if err := r.Client.Update(ctx, conn); err != nil {
+					t.Fatalf("failed to update the connection: %v", err)
+				}
+			}
+
+			previous := domain.PreviousTarget{TargetReference: *ref, Target: url + "|tenant"}
+			mc, err := r.NewPreviousClient(ctx, test.current, previous)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("expected %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, err := mc.ListRules(ctx, ""); err != nil {
+				t.Fatalf("failed to reach Mimir: %v", err)
+			}
+			if got := lastHeader().Get("Authorization"); got != "Bearer "+test.token {
+				t.Errorf("expected the current credentials to be used, got %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package mimirconnection
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+// connectionCheckInterval is the interval at which the reachability of a connection is checked again
+const connectionCheckInterval = 5 * time.Minute
+
+// MimirConnectionReconciler reconciles a MimirConnection object
+type MimirConnectionReconciler struct {
+	client.Client
+	Scheme      *runtime.Scheme
+	Connections *Resolver
+}
+
+//+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimirconnections,verbs=get;list;watch
+//+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimirconnections/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimirreferencegrants,verbs=get;list;watch
+
+// Reconcile checks that the Mimir instance described by a MimirConnection is reachable
+// and reports it in the status of the MimirConnection.
+func (r *MimirConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	conn := &domain.MimirConnection{}
+	if err := r.Get(ctx, req.NamespacedName, conn); err != nil {
+		if errors.IsNotFound(err) && r.Connections.Pool != nil {
+			// The connection is gone, so are the HTTP clients created from its settings
+			r.Connections.Pool.Invalidate(indexValue(domain.MimirConnectionKind, req.Namespace, req.Name))
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	log.FromContext(ctx).Info("Running reconcile on MimirConnection")
+
+	conn.Status = r.Connections.checkReachability(ctx, conn, &conn.Spec, utils.Referrer{Kind: domain.MimirConnectionKind, Namespace: conn.Namespace})
+
+	return ctrl.Result{RequeueAfter: connectionCheckInterval}, r.Status().Update(ctx, conn)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MimirConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Index MimirConnections by the Secrets and ConfigMaps they reference, so they can be found when one of them changes
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &domain.MimirConnection{}, ReferenceIndex, r.Connections.ConnectionReferenceIndexValues)
+	if err != nil {
+		return err
+	}
+
+	// Check the connection again when a referenced Secret or ConfigMap changes, or when a grant allowing a cross-namespace reference changes
+	reconcileOnReferenceChange := EnqueueReferenceConnections(r.Client, func() client.ObjectList {
+		return &domain.MimirConnectionList{}
+	})
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&domain.MimirConnection{}, builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	return utils.WatchReferences(b, r.Connections.References, reconcileOnReferenceChange).
+		Complete(r)
+}
+
+// ClusterMimirConnectionReconciler reconciles a ClusterMimirConnection object
+type ClusterMimirConnectionReconciler struct {
+	client.Client
+	Scheme      *runtime.Scheme
+	Connections *Resolver
+}
+
+//+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=clustermimirconnections,verbs=get;list;watch
+//+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=clustermimirconnections/status,verbs=get;update;patch
+
+// Reconcile checks that the Mimir instance described by a ClusterMimirConnection is reachable
+// and reports it in the status of the ClusterMimirConnection.
+func (r *ClusterMimirConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	conn := &domain.ClusterMimirConnection{}
+	if err := r.Get(ctx, req.NamespacedName, conn); err != nil {
+		if errors.IsNotFound(err) && r.Connections.Pool != nil {
+			// The connection is gone, so are the HTTP clients created from its settings
+			r.Connections.Pool.Invalidate(indexValue(domain.ClusterMimirConnectionKind, "", req.Name))
+		}
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	log.FromContext(ctx).Info("Running reconcile on ClusterMimirConnection")
+
+	conn.Status = r.Connections.checkReachability(ctx, conn, &conn.Spec, r.Connections.clusterReferrer())
+
+	return ctrl.Result{RequeueAfter: connectionCheckInterval}, r.Status().Update(ctx, conn)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterMimirConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Index ClusterMimirConnections by the Secrets and ConfigMaps they reference, so they can be found when one of them changes
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &domain.ClusterMimirConnection{}, ReferenceIndex, r.Connections.ConnectionReferenceIndexValues)
+	if err != nil {
+		return err
+	}
+
+	// Check the connection again when a referenced Secret or ConfigMap changes, or when a grant allowing a cross-namespace reference changes
+	reconcileOnReferenceChange := EnqueueReferenceConnections(r.Client, func() client.ObjectList {
+		return &domain.ClusterMimirConnectionList{}
+	})
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&domain.ClusterMimirConnection{}, builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	return utils.WatchReferences(b, r.Connections.References, reconcileOnReferenceChange).
+		Complete(r)
+}
+
+// checkReachability pings the Mimir instance of a connection and returns the resulting status
+// Status is set as "Reachable" if Mimir answered on its readiness endpoint, "Degraded" if requests to Mimir
+// are suspended by its circuit breaker, "Unreachable" otherwise
+func (r *Resolver) checkReachability(ctx context.Context, conn client.Object, spec *domain.MimirConnectionSpec, referrer utils.Referrer) domain.MimirConnectionStatus {
+	now := metav1.Now()
+
+	err := r.ping(ctx, spec, referrer, IndexValueFor(conn))
+	if _, degraded := mimirapi.CircuitOpenDelay(err); degraded {
+		return domain.MimirConnectionStatus{
+			Status:        "Degraded",
+			Error:         err.Error(),
+			LastCheckTime: &now,
+		}
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Mimir instance is unreachable")
+
+		return domain.MimirConnectionStatus{
+			Status:        "Unreachable",
+			Error:         err.Error(),
+			LastCheckTime: &now,
+		}
+	}
+
+	return domain.MimirConnectionStatus{
+		Status:        "Reachable",
+		LastCheckTime: &now,
+	}
+}
+
+// ping sends a request to the readiness endpoint of the Mimir instance of a connection
+// The connection is reconciled when its settings or the Secrets and ConfigMaps it references change, so the HTTP clients created from its
+// previous settings are dropped from the Pool first. They are kept as long as the settings don't change,
+// such as on the periodic checks, so that their connections are reused.
+func (r *Resolver) ping(ctx context.Context, spec *domain.MimirConnectionSpec, referrer utils.Referrer, owner string) error {
+	cfg, err := r.clientConfig(ctx, spec, referrer, "", nil)
+	if err != nil {
+		return err
+	}
+
+	if r.Pool != nil {
+		if err := r.Pool.Refresh(owner, cfg); err != nil {
+			return err
+		}
+	}
+
+	mc, err := r.clientFor(owner, cfg)
+	if err != nil {
+		return err
+	}
+
+	return mc.Ping(ctx)
+}
@@ -0,0 +1,209 @@
+package mimirconnection
+
+import (
+	"context"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+const (
+	// ConnectionRefIndex is the name of the field index listing resources by the connection they reference
+	ConnectionRefIndex = "spec.connectionRef"
+
+	// ReferenceIndex is the name of the field index listing resources by the Secrets and ConfigMaps they reference
+	ReferenceIndex = "spec.references"
+)
+
+// RefIndexValue returns the value under which a resource referencing a connection is indexed
+// It returns an empty string if the resource doesn't reference any connection
+func RefIndexValue(namespace string, ref *domain.ConnectionReference) string {
+	if ref == nil {
+		return ""
+	}
+
+	if ref.Kind == domain.ClusterMimirConnectionKind {
+		return indexValue(domain.ClusterMimirConnectionKind, "", ref.Name)
+	}
+
+	return indexValue(domain.MimirConnectionKind, namespace, ref.Name)
+}
+
+// IndexValueFor returns the index value matching the resources that reference a connection
+func IndexValueFor(conn client.Object) string {
+	if _, ok := conn.(*domain.ClusterMimirConnection); ok {
+		return indexValue(domain.ClusterMimirConnectionKind, "", conn.GetName())
+	}
+
+	return indexValue(domain.MimirConnectionKind, conn.GetNamespace(), conn.GetName())
+}
+
+func indexValue(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// ReferenceIndexValues returns the values under which a resource is indexed for each Secret and ConfigMap referenced
+// by its authentication settings and headers, those being looked up in the given namespace unless their reference
+// sets another one
+func ReferenceIndexValues(namespace string, auth *domain.Auth, headers []domain.Header) []string {
+	var keys []string
+	add := func(kind, refNamespace, name string) {
+		if refNamespace == "" {
+			refNamespace = namespace
+		}
+		keys = append(keys, ReferenceIndexValue(kind, refNamespace, name))
+	}
+	secret := func(s *domain.SecretKeySelector) {
+		if s != nil {
+			add(domain.SecretKind, s.Namespace, s.Name)
+		}
+	}
+	secretOrConfigMap := func(s *domain.SecretOrConfigMap) {
+		if s == nil {
+			return
+		}
+		secret(s.Secret)
+		if s.ConfigMap != nil {
+			add(domain.ConfigMapKind, s.ConfigMap.Namespace, s.ConfigMap.Name)
+		}
+	}
+
+	if auth != nil {
+		secret(auth.UserSecretRef)
+		secret(auth.KeySecretRef)
+		secret(auth.TokenSecretRef)
+		tls := func(t *domain.TLSConfig) {
+			if t != nil {
+				secretOrConfigMap(t.CA)
+				secretOrConfigMap(t.Cert)
+				secret(t.KeySecret)
+			}
+		}
+		if auth.OAuth2 != nil {
+			secret(&auth.OAuth2.ClientSecret)
+			tls(auth.OAuth2.TLS)
+		}
+		tls(auth.TLS)
+	}
+
+	for _, header := range headers {
+		secret(header.SecretKeyRef)
+	}
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !slices.Contains(values, key) {
+			values = append(values, key)
+		}
+	}
+
+	return values
+}
+
+// ReferenceIndexValue returns the value under which a resource referencing a Secret or a ConfigMap is indexed
+func ReferenceIndexValue(kind, namespace, name string) string {
+	return indexValue(kind, namespace, name)
+}
+
+// ConnectionReferenceIndexValues returns the values under which a connection is indexed for each Secret and ConfigMap
+// it references
+func (r *Resolver) ConnectionReferenceIndexValues(conn client.Object) []string {
+	switch c := conn.(type) {
+	case *domain.MimirConnection:
+		return ReferenceIndexValues(c.Namespace, c.Spec.Auth, c.Spec.Headers)
+	case *domain.ClusterMimirConnection:
+		return ReferenceIndexValues(r.ClusterResourceNamespace, c.Spec.Auth, c.Spec.Headers)
+	default:
+		return nil
+	}
+}
+
+// EnqueueDependents returns a function listing the resources of a kind that reference a connection
+// It is used by the controllers of those resources to be reconciled again when a connection changes
+func EnqueueDependents(c client.Client, newList func() client.ObjectList) func(context.Context, client.Object) []reconcile.Request {
+	return func(ctx context.Context, conn client.Object) []reconcile.Request {
+		return listRequests(ctx, c, newList(), ConnectionRefIndex, IndexValueFor(conn))
+	}
+}
+
+// EnqueueReferenceDependents returns a function listing the resources of a kind that depend on a Secret or a ConfigMap,
+// either directly or through the connection they reference
+// It is used by the controllers of those resources to be reconciled again when a Secret or a certificate is rotated
+func EnqueueReferenceDependents(c client.Client, newList func() client.ObjectList) utils.ReferenceMapFunc {
+	return func(ctx context.Context, kind string, obj client.Object) []reconcile.Request {
+		value := ReferenceIndexValue(kind, obj.GetNamespace(), obj.GetName())
+		requests := listRequests(ctx, c, newList(), ReferenceIndex, value)
+
+		connections := listRequests(ctx, c, &domain.MimirConnectionList{}, ReferenceIndex, value)
+		for _, conn := range connections {
+			ref := &domain.ConnectionReference{Kind: domain.MimirConnectionKind, Name: conn.Name}
+			requests = appendUnique(requests, listRequests(ctx, c, newList(), ConnectionRefIndex, RefIndexValue(conn.Namespace, ref))...)
+		}
+
+		clusterConnections := listRequests(ctx, c, &domain.ClusterMimirConnectionList{}, ReferenceIndex, value)
+		for _, conn := range clusterConnections {
+			ref := &domain.ConnectionReference{Kind: domain.ClusterMimirConnectionKind, Name: conn.Name}
+			requests = appendUnique(requests, listRequests(ctx, c, newList(), ConnectionRefIndex, RefIndexValue("", ref))...)
+		}
+
+		return requests
+	}
+}
+
+// EnqueueReferenceConnections returns a function listing the connections of a kind that reference a Secret or a ConfigMap
+func EnqueueReferenceConnections(c client.Client, newList func() client.ObjectList) utils.ReferenceMapFunc {
+	return func(ctx context.Context, kind string, obj client.Object) []reconcile.Request {
+		return listRequests(ctx, c, newList(), ReferenceIndex, ReferenceIndexValue(kind, obj.GetNamespace(), obj.GetName()))
+	}
+}
+
+// listRequests lists the resources matching a field index value and returns a reconcile request for each of them
+func listRequests(ctx context.Context, c client.Client, list client.ObjectList, field, value string) []reconcile.Request {
+	if err := c.List(ctx, list, client.MatchingFields{field: value}); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list resources by index", "index", field, "value", value)
+		return []reconcile.Request{}
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to extract resources listed by index", "index", field, "value", value)
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+	}
+
+	return requests
+}
+
+// appendUnique appends requests to a list of requests, skipping those already in the list
+func appendUnique(requests []reconcile.Request, others ...reconcile.Request) []reconcile.Request {
+	for _, other := range others {
+		found := false
+		for _, request := range requests {
+			if request == other {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			requests = append(requests, other)
+		}
+	}
+
+	return requests
+}
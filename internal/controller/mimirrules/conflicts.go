@@ -0,0 +1,178 @@
+package mimirrules
+
+import (
+	"context"
+	"slices"
+
+	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+// targetIndex indexes MimirRules by the Mimir instance and tenant they synchronize their rules to
+const targetIndex = "status.target"
+
+// indexByTarget returns the value under which a MimirRules is indexed in targetIndex
+func indexByTarget(o client.Object) []string {
+	if target := o.(*domain.MimirRules).Status.Target; target != "" {
+		return []string{mimirapi.NormalizeTarget(target)}
+	}
+	return nil
+}
+
+// listByTarget lists the MimirRules targeting the same tenant as a MimirRules, mr included
+// MimirRules are only read, they don't need to be copied out of the cache
+func (r *MimirRulesReconciler) listByTarget(ctx context.Context, mr *domain.MimirRules) (*domain.MimirRulesList, error) {
+	list := &domain.MimirRulesList{}
+	err := r.List(ctx, list, client.MatchingFields{targetIndex: mimirapi.NormalizeTarget(mr.Status.Target)}, client.UnsafeDisableDeepCopy)
+
+	return list, err
+}
+
+// precedes returns whether a MimirRules takes precedence over another one targeting the same tenant
+// The oldest MimirRules synchronizes the PrometheusRules selected by both
+func precedes(a, b *domain.MimirRules) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// claimsRules returns whether a MimirRules claims the PrometheusRules it selects over the newer MimirRules
+// targeting the same tenant
+// Suspended MimirRules, MimirRules being deleted and MimirRules in dry-run mode don't synchronize their rules,
+// so they leave them to the other MimirRules
+func (r *MimirRulesReconciler) claimsRules(mr *domain.MimirRules) bool {
+	return !mr.Spec.Suspend && mr.DeletionTimestamp == nil && !r.dryRun(mr)
+}
+
+// findPrecedingRivals returns the MimirRules targeting the same tenant as a MimirRules, taking precedence over it
+// and claiming their rules, the oldest first
+func (r *MimirRulesReconciler) findPrecedingRivals(ctx context.Context, mr *domain.MimirRules) ([]domain.MimirRules, error) {
+	if mr.Status.Target == "" {
+		return nil, nil
+	}
+
+	list, err := r.listByTarget(ctx, mr)
+	if err != nil {
+		return nil, err
+	}
+
+	var rivals []domain.MimirRules
+	for _, item := range list.Items {
+		if item.UID != mr.UID && r.claimsRules(&item) && precedes(&item, mr) {
+			rivals = append(rivals, item)
+		}
+	}
+	slices.SortFunc(rivals, func(a, b domain.MimirRules) int {
+		if precedes(&a, &b) {
+			return -1
+		}
+		return 1
+	})
+
+	return rivals, nil
+}
+
+// namespacesTakenOver returns the Mimir namespaces of a MimirRules now synchronized by other MimirRules targeting
+// the same tenant, for example since it was suspended or started being deleted
+// Those namespaces must not be deleted along with the MimirRules, as they hold the rules of the other MimirRules
+func (r *MimirRulesReconciler) namespacesTakenOver(ctx context.Context, mr *domain.MimirRules) ([]string, error) {
+	if mr.Status.Target == "" {
+		return nil, nil
+	}
+
+	list, err := r.listByTarget(ctx, mr)
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaces []string
+	for _, item := range list.Items {
+		if item.UID == mr.UID || !r.claimsRules(&item) {
+			continue
+		}
+		for _, namespace := range managedNamespaces(&item) {
+			if slices.Contains(managedNamespaces(mr), namespace) && !slices.Contains(namespaces, namespace) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+
+	return namespaces, nil
+}
+
+// claimant returns the first of the rivals selecting a PrometheusRule, or nil if none of them selects it
+func (r *MimirRulesReconciler) claimant(rivals []domain.MimirRules, rule *prometheus.PrometheusRule) *domain.MimirRules {
+	for i := range rivals {
+		if r.selectors.matches(&rivals[i], rule.Labels) {
+			return &rivals[i]
+		}
+	}
+
+	return nil
+}
+
+// releaseNamespace forgets a Mimir namespace now synchronized by another MimirRules, without deleting it from Mimir
+func releaseNamespace(mr *domain.MimirRules, namespace string) {
+	delete(mr.Status.Digests, namespace)
+	removeManagedNamespace(mr, namespace)
+	mr.Status.RefRules = slices.DeleteFunc(mr.Status.RefRules, func(ref string) bool { return ref == namespace })
+}
+
+// reportConflicts records the PrometheusRules of a MimirRules synchronized by other MimirRules in its conditions and events
+func (r *MimirRulesReconciler) reportConflicts(mr *domain.MimirRules) {
+	var conflicts []string
+	for _, result := range mr.Status.Rules {
+		if result.State == domain.RuleConflict {
+			conflicts = append(conflicts, result.Namespace+"/"+result.Name)
+		}
+	}
+	utils.SetConflictCondition(&mr.Status.Conditions, mr.Generation, conflicts)
+
+	if len(conflicts) > 0 {
+		r.Recorder.Eventf(mr, corev1.EventTypeWarning, domain.ReasonConflict, "PrometheusRules are synchronized by older MimirRules targeting the same tenant: %s", utils.JoinItems(conflicts))
+	}
+}
+
+// reconcileOnRivalChange sends a reconcile request to the MimirRules targeting the same tenant as a MimirRules
+// that changed or was deleted, and which it took precedence over, as they might have to synchronize its rules
+func (r *MimirRulesReconciler) reconcileOnRivalChange(ctx context.Context, obj client.Object) []reconcile.Request {
+	mr := obj.(*domain.MimirRules)
+	if mr.Status.Target == "" {
+		return nil
+	}
+
+	list, err := r.listByTarget(ctx, mr)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to list the MimirRules targeting the same tenant after a MimirRules changed")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, item := range list.Items {
+		if item.UID != mr.UID && precedes(mr, &item) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+		}
+	}
+
+	return requests
+}
+
+// targetChangedPredicate lets through updates of MimirRules whose target was set or changed
+var targetChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectOld.(*domain.MimirRules).Status.Target != e.ObjectNew.(*domain.MimirRules).Status.Target
+	},
+}
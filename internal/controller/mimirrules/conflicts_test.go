@@ -0,0 +1,166 @@
+package mimirrules
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestPrecedes(t *testing.T) {
+	now := time.Now()
+	rules := func(namespace, name string, created time.Time) *domain.MimirRules {
+		return &domain.MimirRules{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, CreationTimestamp: metav1.NewTime(created)}}
+	}
+
+	tests := map[string]struct {
+		a, b *domain.MimirRules
+	}{
+		"older":               {a: rules("b", "b", now.Add(-time.Minute)), b: rules("a", "a", now)},
+		"same age, namespace": {a: rules("a", "b", now), b: rules("b", "a", now)},
+		"same age, name":      {a: rules("a", "a", now), b: rules("a", "b", now)},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if !precedes(test.a, test.b) || precedes(test.b, test.a) {
+				t.Errorf("expected %s/%s to take precedence over %s/%s", test.a.Namespace, test.a.Name, test.b.Namespace, test.b.Name)
+			}
+		})
+	}
+}
+
+// newRivals returns two MimirRules selecting the same PrometheusRules on the same tenant, the first being the oldest
+func newRivals(url string) (*domain.MimirRules, *domain.MimirRules) {
+	older := newMimirRules("older", url, map[string]string{"team": "a"})
+	older.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	newer := newMimirRules("newer", url, map[string]string{"team": "a"})
+	newer.CreationTimestamp = metav1.NewTime(time.Now().Truncate(time.Second))
+
+	return older, newer
+}
+
+func TestConflictingMimirRules(t *testing.T) {
+	ruler := newFakeRuler(t)
+	older, newer := newRivals(ruler.URL)
+	r := newTestReconciler(t, older, newer, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+
+	runReconcile(t, r, "older")
+	ruler.takeRequests()
+
+	mr := runReconcile(t, r, "newer")
+	if requests := ruler.takeRequests(); slices.Contains(requests, "POST /default_a") {
+		t.Errorf("expected the rules of the older MimirRules not to be pushed again, got %v", requests)
+	}
+	if len(mr.Status.Rules) != 1 || mr.Status.Rules[0].State != domain.RuleConflict {
+		t.Errorf("expected the PrometheusRule to be reported as a conflict, got %+v", mr.Status.Rules)
+	}
+	if len(mr.Status.ManagedNamespaces) != 0 {
+		t.Errorf("expected the newer MimirRules not to manage the namespace, got %v", mr.Status.ManagedNamespaces)
+	}
+}
+
+func TestRivalsWithEquivalentURLs(t *testing.T) {
+	ruler := newFakeRuler(t)
+	older, newer := newRivals(ruler.URL + "/")
+	newer.Spec.URL = ruler.URL
+	r := newTestReconciler(t, older, newer, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+
+	runReconcile(t, r, "older")
+	mr := runReconcile(t, r, "newer")
+
+	if len(mr.Status.Rules) != 1 || mr.Status.Rules[0].State != domain.RuleConflict {
+		t.Errorf("expected the URLs to target the same tenant, got %+v", mr.Status.Rules)
+	}
+}
+
+func TestDryRunRivalDoesNotClaimRules(t *testing.T) {
+	tests := map[string]struct {
+		globalDryRun bool
+	}{
+		"resource dry run": {},
+		"global dry run":   {globalDryRun: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ruler := newFakeRuler(t)
+			older, newer := newRivals(ruler.URL)
+			older.Spec.DryRun = !test.globalDryRun
+			r := newTestReconciler(t, older, newer, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+			r.DryRun = test.globalDryRun
+
+			runReconcile(t, r, "older")
+			mr := runReconcile(t, r, "newer")
+
+			// In global dry-run mode no MimirRules claims its rules, each of them planning every rule it selects
+			if test.globalDryRun {
+				if mr.Status.Plan == nil || !slices.Equal(mr.Status.Plan.Create, []string{"default_a"}) {
+					t.Errorf("expected the newer MimirRules to plan the rules, got %+v", mr.Status.Plan)
+				}
+				return
+			}
+			if len(mr.Status.Rules) != 1 || mr.Status.Rules[0].State == domain.RuleConflict ||
+				!slices.Equal(mr.Status.ManagedNamespaces, []string{"default_a"}) {
+				t.Errorf("expected the newer MimirRules to synchronize the rules, got %+v and %v", mr.Status.Rules, mr.Status.ManagedNamespaces)
+			}
+		})
+	}
+}
+
+func TestSuspendedRivalGivesUpRules(t *testing.T) {
+	ruler := newFakeRuler(t)
+	older, newer := newRivals(ruler.URL)
+	r := newTestReconciler(t, older, newer, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+
+	runReconcile(t, r, "older")
+	runReconcile(t, r, "newer")
+
+	update(t, r, older, func() { older.Spec.Suspend = true })
+	runReconcile(t, r, "older")
+
+	mr := runReconcile(t, r, "newer")
+	if len(mr.Status.Rules) != 1 || mr.Status.Rules[0].State == domain.RuleConflict {
+		t.Errorf("expected the newer MimirRules to take over the PrometheusRule, got %+v", mr.Status.Rules)
+	}
+	if !slices.Equal(mr.Status.ManagedNamespaces, []string{"default_a"}) {
+		t.Errorf("expected the newer MimirRules to manage the namespace, got %v", mr.Status.ManagedNamespaces)
+	}
+}
+
+func TestDeletedRivalLeavesTakenOverNamespaces(t *testing.T) {
+	ruler := newFakeRuler(t)
+	older, newer := newRivals(ruler.URL)
+	r := newTestReconciler(t, older, newer,
+		newPrometheusRule("a", map[string]string{"team": "a"}, "A"),
+		newPrometheusRule("b", map[string]string{"team": "a", "owner": "older"}, "B"),
+	)
+
+	runReconcile(t, r, "older")
+	runReconcile(t, r, "newer")
+
+	// The newer MimirRules takes over the rules once the older one is being deleted, while Mimir can't be reached
+	update(t, r, older, func() { older.Spec.URL = "http://127.0.0.1:1" })
+	if err := r.Delete(context.Background(), older); err != nil {
+		t.Fatalf("failed to delete the older MimirRules: %v", err)
+	}
+	mr := runReconcile(t, r, "newer")
+	if !slices.Equal(mr.Status.ManagedNamespaces, []string{"default_a", "default_b"}) {
+		t.Fatalf("expected the newer MimirRules to take over the namespaces, got %v", mr.Status.ManagedNamespaces)
+	}
+
+	// The older MimirRules doesn't delete the namespaces the newer one took over
+	update(t, r, older, func() { older.Spec.URL = ruler.URL })
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(older)}); err != nil {
+		t.Fatalf("failed to reconcile the deletion of the older MimirRules: %v", err)
+	}
+	if names := ruler.namespaceNames(); !slices.Equal(names, []string{"default_a", "default_b"}) {
+		t.Errorf("expected the namespaces taken over to be left in Mimir, got %v", names)
+	}
+}
@@ -0,0 +1,79 @@
+package mimirrules
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi/rwrulefmt"
+	"github.com/AmiditeX/mimir-operator/internal/metrics"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+// digestRules returns the digest of the rules of each namespace of a rule map
+func digestRules(ruleMap map[string]string) (map[string]string, error) {
+	digests := make(map[string]string, len(ruleMap))
+	for namespace, content := range ruleMap {
+		groups, err := mimirapi.ParseRuleGroups(content)
+		if err != nil {
+			return nil, err
+		}
+
+		digests[namespace], err = mimirapi.RuleGroupsDigest(groups)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return digests, nil
+}
+
+// detectDrift returns the Mimir namespaces whose rules were changed outside the operator
+// applied holds the digests of the rules last pushed by the operator, and desired those of the rules to push
+// A namespace has drifted if its rules in Mimir don't match those last pushed, or if it was deleted from Mimir
+// while still wanted
+func detectDrift(live map[string][]rwrulefmt.RuleGroup, applied, desired map[string]string) ([]string, error) {
+	var drifted []string
+
+	for namespace, digest := range applied {
+		groups, ok := live[namespace]
+		if !ok {
+			// Namespaces that are not wanted anymore were going to be deleted anyway
+			if _, wanted := desired[namespace]; wanted {
+				drifted = append(drifted, namespace)
+			}
+			continue
+		}
+
+		liveDigest, err := mimirapi.RuleGroupsDigest(groups)
+		if err != nil {
+			return nil, err
+		}
+		if liveDigest != digest {
+			drifted = append(drifted, namespace)
+		}
+	}
+
+	sort.Strings(drifted)
+	return drifted, nil
+}
+
+// reportDrift records the drift found during the synchronization of a MimirRules in its status, metrics and events
+// repaired is whether the drift is going to be overwritten by the synchronization
+func (r *MimirRulesReconciler) reportDrift(mr *domain.MimirRules, drifted []string, repaired bool) {
+	mr.Status.DriftedNamespaces = drifted
+	utils.SetDriftCondition(&mr.Status.Conditions, mr.Generation, drifted, repaired)
+	metrics.SetDrift(domain.MimirRulesKind, mr.Namespace, mr.Name, mr.Spec.ID, len(drifted))
+
+	if len(drifted) == 0 {
+		return
+	}
+
+	if repaired {
+		r.Recorder.Eventf(mr, corev1.EventTypeNormal, domain.ReasonDriftRepaired, "Repaired the rules of namespaces changed in Mimir outside the operator: %s", utils.JoinItems(drifted))
+	} else {
+		r.Recorder.Eventf(mr, corev1.EventTypeWarning, domain.ReasonDriftDetected, "Rules of namespaces were changed in Mimir outside the operator: %s", utils.JoinItems(drifted))
+	}
+}
@@ -0,0 +1,116 @@
+package mimirrules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+// dryRun returns whether the changes a MimirRules would make in Mimir are only planned
+func (r *MimirRulesReconciler) dryRun(mr *domain.MimirRules) bool {
+	return r.DryRun || mr.Spec.DryRun
+}
+
+// planRules computes the changes the synchronization of a MimirRules would make in Mimir, without making them
+// The rules are rendered as they would be synchronized, and compared with the rules of the tenant in Mimir
+func (r *MimirRulesReconciler) planRules(ctx context.Context, mc *mimirapi.MimirClient, mr *domain.MimirRules) error {
+	rules, err := r.findPrometheusRulesFromLabels(ctx, mr.Spec.Rules.Selectors)
+	if err != nil {
+		return err
+	}
+
+	// PrometheusRules synchronized by older MimirRules targeting the same tenant are neither pushed nor deleted
+	rivals, err := r.findPrecedingRivals(ctx, mr)
+	if err != nil {
+		return err
+	}
+	var claimed []string
+	rules.Items = slices.DeleteFunc(rules.Items, func(rule *prometheus.PrometheusRule) bool {
+		if r.claimant(rivals, rule) == nil {
+			return false
+		}
+
+		claimed = append(claimed, rule.Namespace+"_"+rule.Name)
+		return true
+	})
+
+	unpackedRules, desired, invalid, err := r.renderRules(mr, rules)
+	if err != nil {
+		return err
+	}
+	// The rendered output only lives in the cluster, it is still written to review the rules that would be pushed
+	r.publishRenderedOutput(ctx, mr, unpackedRules)
+
+	// Mimir answers with a 404 when the tenant has no rules
+	live, err := mc.ListRules(ctx, "")
+	if err != nil && !errors.Is(err, mimirapi.ErrResourceNotFound) {
+		return err
+	}
+
+	// Invalid rules would not be pushed, they fail the dry run like they would fail the synchronization
+	plan := &domain.RulesPlan{}
+	for namespace := range unpackedRules {
+		if _, ok := invalid[namespace]; ok {
+			continue
+		}
+
+		groups, ok := live[namespace]
+		if !ok {
+			plan.Create = append(plan.Create, namespace)
+			continue
+		}
+
+		liveDigest, err := mimirapi.RuleGroupsDigest(groups)
+		if err != nil {
+			return err
+		}
+		if liveDigest != desired[namespace] {
+			plan.Update = append(plan.Update, namespace)
+		} else {
+			plan.Unchanged++
+		}
+	}
+	sort.Strings(plan.Create)
+	sort.Strings(plan.Update)
+
+	managed := slices.DeleteFunc(slices.Clone(managedNamespaces(mr)), func(namespace string) bool {
+		return slices.Contains(claimed, namespace)
+	})
+	plan.Delete = diffRuleNamespaces(live, unpackedRules, managed)
+
+	// The plan is computed on every resync, it is only reported again when it changes
+	if !samePlan(mr.Status.Plan, plan) {
+		r.Recorder.Event(mr, corev1.EventTypeNormal, domain.ReasonDryRun, describePlan(plan))
+	}
+	mr.Status.Plan = plan
+
+	return joinNamespaceErrors(invalid)
+}
+
+// samePlan returns whether two plans list the same changes
+func samePlan(a, b *domain.RulesPlan) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return slices.Equal(a.Create, b.Create) && slices.Equal(a.Update, b.Update) && slices.Equal(a.Delete, b.Delete) &&
+		a.Unchanged == b.Unchanged
+}
+
+// describePlan returns a description of the changes of a plan
+func describePlan(plan *domain.RulesPlan) string {
+	return fmt.Sprintf("Dry run: would create %d namespaces [%s], update %d namespaces [%s] and delete %d namespaces [%s], %d namespaces are up to date",
+		len(plan.Create), utils.JoinItems(plan.Create),
+		len(plan.Update), utils.JoinItems(plan.Update),
+		len(plan.Delete), utils.JoinItems(plan.Delete),
+		plan.Unchanged)
+}
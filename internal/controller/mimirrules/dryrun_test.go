@@ -0,0 +1,97 @@
+package mimirrules
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// dryRunEvents returns the number of DryRun events recorded since it was last called
+func dryRunEvents(recorder *record.FakeRecorder) int {
+	count := 0
+	for {
+		select {
+		case event := <-recorder.Events:
+			if strings.Contains(event, domain.ReasonDryRun) {
+				count++
+			}
+		default:
+			return count
+		}
+	}
+}
+
+func TestDryRunPlansChanges(t *testing.T) {
+	ruler := newFakeRuler(t)
+	mr := newMimirRules("rules", ruler.URL, map[string]string{"team": "a"})
+	ruleA := newPrometheusRule("a", map[string]string{"team": "a"}, "A")
+	ruleB := newPrometheusRule("b", map[string]string{"team": "a"}, "B")
+	ruleC := newPrometheusRule("c", map[string]string{"team": "b"}, "C")
+	ruleD := newPrometheusRule("d", map[string]string{"team": "a"}, "D")
+	r := newTestReconciler(t, mr, ruleA, ruleB, ruleC, ruleD)
+	recorder := record.NewFakeRecorder(100)
+	r.Recorder = recorder
+
+	runReconcile(t, r, "rules")
+	ruler.takeRequests()
+
+	// a is changed, b isn't selected anymore, c is now selected and d is left as is
+	update(t, r, mr, func() { mr.Spec.DryRun = true })
+	update(t, r, ruleA, func() {
+		ruleA.Spec.Groups[0].Rules = append(ruleA.Spec.Groups[0].Rules, prometheus.Rule{Alert: "A2", Expr: intstr.FromString("up == 1")})
+	})
+	update(t, r, ruleB, func() { ruleB.Labels = map[string]string{"team": "b"} })
+	update(t, r, ruleC, func() { ruleC.Labels = map[string]string{"team": "a"} })
+	mr = runReconcile(t, r, "rules")
+
+	if requests := ruler.takeRequests(); !slices.Equal(requests, []string{"GET"}) {
+		t.Errorf("expected the rules to only be listed, got %v", requests)
+	}
+	if names := ruler.namespaceNames(); !slices.Equal(names, []string{"default_a", "default_b", "default_d"}) {
+		t.Errorf("expected Mimir to be left as is, got %v", names)
+	}
+	if mr.Status.Status != "DryRun" || !meta.IsStatusConditionTrue(mr.Status.Conditions, domain.ConditionDryRun) {
+		t.Errorf("expected the MimirRules to be reported in dry-run mode, got %s and %+v", mr.Status.Status, mr.Status.Conditions)
+	}
+
+	plan := mr.Status.Plan
+	if plan == nil {
+		t.Fatalf("expected the changes to be planned")
+	}
+	if !slices.Equal(plan.Create, []string{"default_c"}) || !slices.Equal(plan.Update, []string{"default_a"}) ||
+		!slices.Equal(plan.Delete, []string{"default_b"}) || plan.Unchanged != 1 {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if events := dryRunEvents(recorder); events != 1 {
+		t.Errorf("expected the plan to be reported once, got %d events", events)
+	}
+
+	// The plan is only reported again when it changes
+	runReconcile(t, r, "rules")
+	if events := dryRunEvents(recorder); events != 0 {
+		t.Errorf("expected the unchanged plan not to be reported, got %d events", events)
+	}
+	update(t, r, ruleD, func() { ruleD.Labels = map[string]string{"team": "b"} })
+	mr = runReconcile(t, r, "rules")
+	if events := dryRunEvents(recorder); events != 1 || !slices.Equal(mr.Status.Plan.Delete, []string{"default_b", "default_d"}) {
+		t.Errorf("expected the changed plan to be reported, got %d events and %+v", events, mr.Status.Plan)
+	}
+
+	// The plan is dropped once the changes are synchronized
+	update(t, r, mr, func() { mr.Spec.DryRun = false })
+	mr = runReconcile(t, r, "rules")
+
+	if names := ruler.namespaceNames(); !slices.Equal(names, []string{"default_a", "default_c"}) {
+		t.Errorf("expected the planned changes to be made, got %v", names)
+	}
+	if mr.Status.Status != "Synced" || mr.Status.Plan != nil {
+		t.Errorf("expected the rules to be synced without a plan, got %s and %+v", mr.Status.Status, mr.Status.Plan)
+	}
+}
@@ -0,0 +1,256 @@
+package mimirrules
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/metrics"
+)
+
+// changedRules tracks the PrometheusRules that changed since the last synchronization of each MimirRules,
+// so that only their Mimir namespaces are synchronized
+type changedRules struct {
+	mu    sync.Mutex
+	rules map[types.NamespacedName]map[types.NamespacedName]struct{}
+}
+
+// add records that a PrometheusRule selected by a MimirRules changed
+func (c *changedRules) add(mimirRules, rule types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rules == nil {
+		c.rules = map[types.NamespacedName]map[types.NamespacedName]struct{}{}
+	}
+	if c.rules[mimirRules] == nil {
+		c.rules[mimirRules] = map[types.NamespacedName]struct{}{}
+	}
+	c.rules[mimirRules][rule] = struct{}{}
+}
+
+// take returns the PrometheusRules that changed for a MimirRules and forgets them
+func (c *changedRules) take(mimirRules types.NamespacedName) []types.NamespacedName {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rules := make([]types.NamespacedName, 0, len(c.rules[mimirRules]))
+	for rule := range c.rules[mimirRules] {
+		rules = append(rules, rule)
+	}
+	delete(c.rules, mimirRules)
+
+	return rules
+}
+
+// canSyncIncrementally returns whether only the changed PrometheusRules of a MimirRules can be synchronized
+// A full synchronization is needed when the MimirRules changed since every namespace was last synchronized, and
+// when it is requested. Namespaces that failed to synchronize don't need one, they are retried on their own.
+func canSyncIncrementally(mr *domain.MimirRules) bool {
+	reconcileNow := mr.Annotations[domain.ReconcileNowAnnotation]
+
+	return mr.Status.FullSyncGeneration == mr.Generation &&
+		mr.Status.Digests != nil &&
+		(reconcileNow == "" || reconcileNow == mr.Status.LastHandledReconcileAt)
+}
+
+// syncChangedRules synchronizes the Mimir namespaces of PrometheusRules that changed, without listing the
+// rules of the tenant in Mimir
+// The rules of a PrometheusRule are pushed if they changed since they were last pushed, and deleted from Mimir
+// if it was deleted or isn't selected by the MimirRules anymore
+// The namespaces that failed to synchronize earlier are synchronized again along with them
+func (r *MimirRulesReconciler) syncChangedRules(ctx context.Context, mc *mimirapi.MimirClient, mr *domain.MimirRules, changed []types.NamespacedName) error {
+	toPush, digests, rendered := map[string]string{}, map[string]string{}, map[string]string{}
+	rejected := map[string]error{}
+	var toDelete, unrendered []string
+	var selected []types.NamespacedName
+
+	// The changes are forgotten if the synchronization fails before reaching Mimir, the next one is a full one
+	fullSyncGeneration := mr.Status.FullSyncGeneration
+	mr.Status.FullSyncGeneration = 0
+	changed = withFailedRules(mr, changed)
+
+	rivals, err := r.findPrecedingRivals(ctx, mr)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range changed {
+		namespace := key.Namespace + "_" + key.Name
+
+		rules, err := r.findSelectedRule(ctx, mr.Spec.Rules.Selectors, key)
+		if err != nil {
+			return err
+		}
+
+		if len(rules.Items) == 0 {
+			unrendered = append(unrendered, namespace)
+			if slices.Contains(managedNamespaces(mr), namespace) {
+				toDelete = append(toDelete, namespace)
+			}
+
+			mr.Status.RefRules = slices.DeleteFunc(mr.Status.RefRules, func(ref string) bool { return ref == namespace })
+			setRuleResult(mr, key.Namespace, key.Name, nil)
+			continue
+		}
+
+		// The PrometheusRule is synchronized by an older MimirRules targeting the same tenant
+		if owner := r.claimant(rivals, rules.Items[0]); owner != nil {
+			unrendered = append(unrendered, namespace)
+			releaseNamespace(mr, namespace)
+			result := conflictResult(key.Namespace, key.Name, owner)
+			setRuleResult(mr, key.Namespace, key.Name, &result)
+			continue
+		}
+		selected = append(selected, key)
+
+		unpackedRules, desired, invalid, err := r.renderRules(mr, rules)
+		if err != nil {
+			return err
+		}
+		rendered[namespace] = unpackedRules[namespace]
+
+		// Invalid rules are not pushed, the rules last pushed for their namespace being left in Mimir
+		if err, ok := invalid[namespace]; ok {
+			rejected[namespace] = err
+		} else if mr.Status.Digests[namespace] != desired[namespace] {
+			toPush[namespace] = unpackedRules[namespace]
+			digests[namespace] = desired[namespace]
+		}
+
+		if !slices.Contains(mr.Status.RefRules, namespace) {
+			mr.Status.RefRules = append(mr.Status.RefRules, namespace)
+			slices.Sort(mr.Status.RefRules)
+		}
+	}
+
+	r.updateRenderedOutput(ctx, mr, rendered, unrendered)
+
+	// Every namespace is synchronized even if some fail, their errors being reported together
+	failed := mc.CreateRuleGroupsStr(ctx, toPush)
+	for namespace := range toPush {
+		if _, ok := failed[namespace]; !ok {
+			mr.Status.Digests[namespace] = digests[namespace]
+			addManagedNamespace(mr, namespace)
+			r.Recorder.Eventf(mr, corev1.EventTypeNormal, "RulesPushed", "Pushed the rules of namespace %s to Mimir", namespace)
+		}
+	}
+
+	for namespace, err := range rejected {
+		failed[namespace] = err
+	}
+
+	// The result of rules that were not pushed again is kept, unless they are invalid
+	for _, key := range selected {
+		namespace := key.Namespace + "_" + key.Name
+		_, attempted := toPush[namespace]
+		if _, invalid := rejected[namespace]; !attempted && !invalid && hasRuleResult(mr, key.Namespace, key.Name) {
+			continue
+		}
+
+		result := ruleResult(key.Namespace, key.Name, failed[namespace], false)
+		setRuleResult(mr, key.Namespace, key.Name, &result)
+	}
+
+	failedDeletions := mc.DeleteNamespaces(ctx, toDelete)
+	for _, namespace := range toDelete {
+		if err, ok := failedDeletions[namespace]; ok && !errors.Is(err, mimirapi.ErrResourceNotFound) {
+			failed[namespace] = err
+			continue
+		}
+		delete(mr.Status.Digests, namespace)
+		removeManagedNamespace(mr, namespace)
+		r.Recorder.Eventf(mr, corev1.EventTypeNormal, "RulesDeleted", "Deleted the rules of namespace %s from Mimir", namespace)
+	}
+
+	r.reportConflicts(mr)
+
+	if err := r.updateRuleCounts(ctx, mr); err != nil {
+		log.FromContext(ctx).Error(err, "failed to count the rules of the MimirRules")
+	}
+
+	mr.Status.FullSyncGeneration = fullSyncGeneration
+	mr.Status.FailedNamespaces = sortedNamespaces(failed)
+
+	return joinNamespaceErrors(failed)
+}
+
+// withFailedRules returns the changed PrometheusRules along with those whose Mimir namespaces failed to synchronize
+func withFailedRules(mr *domain.MimirRules, changed []types.NamespacedName) []types.NamespacedName {
+	for _, namespace := range mr.Status.FailedNamespaces {
+		ruleNamespace, name, _ := strings.Cut(namespace, "_")
+
+		key := types.NamespacedName{Namespace: ruleNamespace, Name: name}
+		if !slices.Contains(changed, key) {
+			changed = append(changed, key)
+		}
+	}
+
+	return changed
+}
+
+// updateRuleCounts records the number of rule groups and rules synchronized by a MimirRules, as a full
+// synchronization does
+// The PrometheusRules it synchronizes are read from the cache, with the overrides applied, so that the other
+// Mimir namespaces don't have to be rendered again
+func (r *MimirRulesReconciler) updateRuleCounts(ctx context.Context, mr *domain.MimirRules) error {
+	rules := &prometheus.PrometheusRuleList{}
+	for _, ref := range mr.Status.RefRules {
+		namespace, name, _ := strings.Cut(ref, "_")
+
+		rule := &prometheus.PrometheusRule{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, rule); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		rules.Items = append(rules.Items, rule)
+	}
+	applyOverrides(mr.Spec.Overrides, rules)
+
+	groupCount, ruleCount := countRules(rules)
+	metrics.SetRuleCounts(mr.Namespace, mr.Name, mr.Spec.ID, groupCount, ruleCount)
+
+	return nil
+}
+
+// findSelectedRule returns a list holding the PrometheusRule with the given key if it exists and is selected
+// by one of the selectors, or an empty list otherwise
+func (r *MimirRulesReconciler) findSelectedRule(ctx context.Context, selectors []*metav1.LabelSelector, key types.NamespacedName) (*prometheus.PrometheusRuleList, error) {
+	list := &prometheus.PrometheusRuleList{}
+
+	rule := &prometheus.PrometheusRule{}
+	if err := r.Get(ctx, key, rule); err != nil {
+		if apierrors.IsNotFound(err) {
+			return list, nil
+		}
+		return nil, err
+	}
+
+	for _, labelSelector := range selectors {
+		sel, err := metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		if sel.Matches(labels.Set(rule.Labels)) {
+			list.Items = append(list.Items, rule)
+			break
+		}
+	}
+
+	return list, nil
+}
@@ -0,0 +1,131 @@
+package mimirrules
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestSyncChangedRules(t *testing.T) {
+	ruler := newFakeRuler(t)
+	ruleA := newPrometheusRule("a", map[string]string{"team": "a"}, "A")
+	ruleB := newPrometheusRule("b", map[string]string{"team": "a"}, "B")
+	r := newTestReconciler(t, newMimirRules("rules", ruler.URL, map[string]string{"team": "a"}), ruleA, ruleB)
+
+	if mr := runReconcile(t, r, "rules"); mr.Status.Status != "Synced" {
+		t.Fatalf("expected the rules to be synced, got %s: %s", mr.Status.Status, mr.Status.Error)
+	}
+	ruler.takeRequests()
+
+	// A changed PrometheusRule is the only one pushed, without listing the rules of the tenant
+	update(t, r, ruleA, func() {
+		ruleA.Spec.Groups[0].Rules = append(ruleA.Spec.Groups[0].Rules, prometheus.Rule{Alert: "A2", Expr: intstr.FromString("up == 1")})
+	})
+	if requests := r.reconcileOnPrometheusRuleChange(context.Background(), ruleA); len(requests) != 1 || requests[0].Name != "rules" {
+		t.Fatalf("expected the MimirRules selecting the PrometheusRule to be reconciled, got %v", requests)
+	}
+
+	mr := runReconcile(t, r, "rules")
+	if requests := ruler.takeRequests(); !slices.Equal(requests, []string{"POST /default_a"}) {
+		t.Errorf("expected only the changed namespace to be pushed, got %v", requests)
+	}
+	if got := len(ruler.groups("default_a")[0].Rules); got != 2 {
+		t.Errorf("expected the changed rules in Mimir, got %d rules", got)
+	}
+	if mr.Status.Status != "Synced" {
+		t.Errorf("expected the rules to be synced, got %s: %s", mr.Status.Status, mr.Status.Error)
+	}
+
+	// A PrometheusRule which isn't selected anymore is deleted from Mimir
+	update(t, r, ruleB, func() {
+		ruleB.Labels = map[string]string{"team": "b"}
+	})
+	if requests := r.reconcileOnPrometheusRuleChange(context.Background(), ruleB); len(requests) != 1 {
+		t.Fatalf("expected the MimirRules which synchronized the PrometheusRule to be reconciled, got %v", requests)
+	}
+
+	mr = runReconcile(t, r, "rules")
+	if requests := ruler.takeRequests(); !slices.Equal(requests, []string{"DELETE /default_b"}) {
+		t.Errorf("expected only the unselected namespace to be deleted, got %v", requests)
+	}
+	if !slices.Equal(mr.Status.ManagedNamespaces, []string{"default_a"}) || !slices.Equal(mr.Status.RefRules, []string{"default_a"}) {
+		t.Errorf("expected the unselected namespace to be forgotten, got %v and %v", mr.Status.ManagedNamespaces, mr.Status.RefRules)
+	}
+}
+
+func TestRetryFailedNamespaces(t *testing.T) {
+	ruler := newFakeRuler(t)
+	ruleA := newPrometheusRule("a", map[string]string{"team": "a"}, "A")
+	ruleB := newPrometheusRule("b", map[string]string{"team": "a"}, "B")
+	r := newTestReconciler(t, newMimirRules("rules", ruler.URL, map[string]string{"team": "a"}), ruleA, ruleB)
+
+	ruler.setRejected("default_b", true)
+	mr := runReconcile(t, r, "rules")
+	if mr.Status.Status != "Failed" || !slices.Equal(mr.Status.FailedNamespaces, []string{"default_b"}) {
+		t.Fatalf("expected default_b to fail, got %s with %v", mr.Status.Status, mr.Status.FailedNamespaces)
+	}
+	ruler.takeRequests()
+
+	// The failed namespace is synchronized again with the changed PrometheusRule, without a full synchronization
+	ruler.setRejected("default_b", false)
+	update(t, r, ruleA, func() {
+		ruleA.Spec.Groups[0].Rules = append(ruleA.Spec.Groups[0].Rules, prometheus.Rule{Alert: "A2", Expr: intstr.FromString("up == 1")})
+	})
+	r.reconcileOnPrometheusRuleChange(context.Background(), ruleA)
+
+	mr = runReconcile(t, r, "rules")
+	requests := ruler.takeRequests()
+	slices.Sort(requests)
+	if !slices.Equal(requests, []string{"POST /default_a", "POST /default_b"}) {
+		t.Errorf("expected the changed and the failed namespaces to be pushed, got %v", requests)
+	}
+	if mr.Status.Status != "Synced" || len(mr.Status.FailedNamespaces) != 0 {
+		t.Errorf("expected the rules to be synced, got %s with %v: %s", mr.Status.Status, mr.Status.FailedNamespaces, mr.Status.Error)
+	}
+}
+
+func TestCanSyncIncrementally(t *testing.T) {
+	synced := &domain.MimirRules{}
+	synced.Generation = 2
+	synced.Status = domain.MimirRulesStatus{Status: "Synced", ObservedGeneration: 2, FullSyncGeneration: 2, Digests: map[string]string{}}
+
+	tests := map[string]struct {
+		mutate   func(mr *domain.MimirRules)
+		expected bool
+	}{
+		"synced": {mutate: func(*domain.MimirRules) {}, expected: true},
+		"failed namespaces": {
+			mutate: func(mr *domain.MimirRules) {
+				mr.Status.Status = "Failed"
+				mr.Status.FailedNamespaces = []string{"default_a"}
+			},
+			expected: true,
+		},
+		"interrupted":         {mutate: func(mr *domain.MimirRules) { mr.Status.FullSyncGeneration = 0 }},
+		"changed":             {mutate: func(mr *domain.MimirRules) { mr.Generation = 3 }},
+		"never fully synced":  {mutate: func(mr *domain.MimirRules) { mr.Status.Digests = nil }},
+		"full sync requested": {mutate: func(mr *domain.MimirRules) { mr.Annotations = map[string]string{domain.ReconcileNowAnnotation: "now"} }},
+		"full sync handled": {
+			mutate: func(mr *domain.MimirRules) {
+				mr.Annotations = map[string]string{domain.ReconcileNowAnnotation: "now"}
+				mr.Status.LastHandledReconcileAt = "now"
+			},
+			expected: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mr := synced.DeepCopy()
+			test.mutate(mr)
+			if got := canSyncIncrementally(mr); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package mimirrules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirconnection"
+	"github.com/AmiditeX/mimir-operator/internal/utils"
+)
+
+// trackTarget records the tenant a MimirRules is synchronized to, target identifying it
+// When the MimirRules targets another tenant, the namespaces it created in the previous one are kept aside
+// to be deleted once it is synchronized to the new one
+func (r *MimirRulesReconciler) trackTarget(ctx context.Context, mr *domain.MimirRules, target string) {
+	if mr.Status.Target != "" && mimirapi.NormalizeTarget(mr.Status.Target) != target && mr.Status.AppliedTarget != nil {
+		mr.Status.PreviousTargets = append(mr.Status.PreviousTargets, domain.PreviousTarget{
+			TargetReference:   *mr.Status.AppliedTarget,
+			Target:            mr.Status.Target,
+			ManagedNamespaces: managedNamespaces(mr),
+		})
+		r.Recorder.Eventf(mr, corev1.EventTypeNormal, "TargetChanged", "Moving the rules from %s to %s", mr.Status.Target, target)
+
+		// Nothing was pushed to the new tenant yet, every namespace is synchronized again
+		recordManagedNamespaces(mr, nil)
+		mr.Status.Digests = nil
+		mr.Status.RefRules = nil
+		mr.Status.DriftedNamespaces = nil
+	}
+
+	// Moving back to a previous tenant, the namespaces created in it are managed again instead of being deleted
+	for i, previous := range mr.Status.PreviousTargets {
+		if mimirapi.NormalizeTarget(previous.Target) == target {
+			for _, namespace := range previous.ManagedNamespaces {
+				addManagedNamespace(mr, namespace)
+			}
+			mr.Status.PreviousTargets = slices.Delete(mr.Status.PreviousTargets, i, i+1)
+			break
+		}
+	}
+
+	mr.Status.Target = target
+	// The fingerprint of the settings used to reach the tenant is recorded along with it, without their credentials
+	reference, err := r.Connections.Reference(ctx, r.target(mr))
+	if err != nil {
+		reference = r.target(mr).Reference()
+	}
+	mr.Status.AppliedTarget = reference
+}
+
+// cleanupPreviousTargets deletes the namespaces a MimirRules created in the tenants it targeted before,
+// or leaves them as is if its rules are orphaned
+// Tenants that can't be cleaned up are kept to be tried again on the next synchronization, their errors
+// being returned and reported by the Migrating condition
+func (r *MimirRulesReconciler) cleanupPreviousTargets(ctx context.Context, mr *domain.MimirRules) error {
+	if r.deletionPolicy(mr) == domain.DeletionPolicyOrphan {
+		for _, previous := range mr.Status.PreviousTargets {
+			r.Recorder.Eventf(mr, corev1.EventTypeNormal, "RulesOrphaned", "Left the rules of %s in Mimir: %s", previous.Target, strings.Join(previous.ManagedNamespaces, ", "))
+		}
+		mr.Status.PreviousTargets = nil
+	}
+
+	var errs []error
+
+	remaining := mr.Status.PreviousTargets[:0]
+	for _, previous := range mr.Status.PreviousTargets {
+		err := r.cleanupPreviousTarget(ctx, mr, previous)
+		// The content of a tenant that can't be reached anymore is left as is, rather than trying again forever
+		if errors.Is(err, mimirconnection.ErrPreviousTargetUnreachable) {
+			r.Recorder.Eventf(mr, corev1.EventTypeWarning, "RulesOrphaned", "Left the rules of %s in Mimir: %v", previous.Target, err)
+			continue
+		}
+		if err != nil {
+			remaining = append(remaining, previous)
+			errs = append(errs, fmt.Errorf("failed to delete the rules of %s: %w", previous.Target, err))
+			continue
+		}
+		r.Recorder.Eventf(mr, corev1.EventTypeNormal, "RulesDeleted", "Deleted the rules of %d namespaces of %s, which is not targeted anymore", len(previous.ManagedNamespaces), previous.Target)
+	}
+	if len(remaining) == 0 {
+		remaining = nil
+	}
+	mr.Status.PreviousTargets = remaining
+
+	err := errors.Join(errs...)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to clean up the tenants previously targeted by MimirRules")
+	}
+	utils.SetMigratingCondition(&mr.Status.Conditions, mr.Generation, err)
+
+	return err
+}
+
+// cleanupPreviousTarget deletes the namespaces a MimirRules created in a tenant it targeted before
+func (r *MimirRulesReconciler) cleanupPreviousTarget(ctx context.Context, mr *domain.MimirRules, previous domain.PreviousTarget) error {
+	mc, err := r.Connections.NewPreviousClient(ctx, r.target(mr), previous)
+	if err != nil {
+		return err
+	}
+
+	failed := mc.DeleteNamespaces(ctx, previous.ManagedNamespaces)
+	for namespace, err := range failed {
+		// Namespaces already deleted from Mimir are fine
+		if errors.Is(err, mimirapi.ErrResourceNotFound) {
+			delete(failed, namespace)
+		}
+	}
+
+	return joinNamespaceErrors(failed)
+}
@@ -0,0 +1,134 @@
+package mimirrules
+
+import (
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestMigrationToAnotherTenant(t *testing.T) {
+	previous, next := newFakeRuler(t), newFakeRuler(t)
+	mr := newMimirRules("rules", previous.URL, map[string]string{"team": "a"})
+	r := newTestReconciler(t, mr, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+
+	runReconcile(t, r, "rules")
+
+	update(t, r, mr, func() { mr.Spec.URL = next.URL })
+	mr = runReconcile(t, r, "rules")
+
+	if mr.Status.Status != "Synced" {
+		t.Fatalf("expected the rules to be synced, got %s: %s", mr.Status.Status, mr.Status.Error)
+	}
+	if names := next.namespaceNames(); !slices.Equal(names, []string{"default_a"}) {
+		t.Errorf("expected the rules to be pushed to the new tenant, got %v", names)
+	}
+	if names := previous.namespaceNames(); len(names) != 0 {
+		t.Errorf("expected the rules to be deleted from the previous tenant, got %v", names)
+	}
+	if len(mr.Status.PreviousTargets) != 0 {
+		t.Errorf("expected the previous tenant to be forgotten, got %+v", mr.Status.PreviousTargets)
+	}
+	if mr.Status.Target != next.URL+"|tenant" {
+		t.Errorf("expected the new tenant to be targeted, got %s", mr.Status.Target)
+	}
+}
+
+func TestMigrationToFailingTenant(t *testing.T) {
+	previous, next := newFakeRuler(t), newFakeRuler(t)
+	mr := newMimirRules("rules", previous.URL, map[string]string{"team": "a"})
+	r := newTestReconciler(t, mr, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+
+	runReconcile(t, r, "rules")
+	previous.takeRequests()
+
+	// The rules are kept in the previous tenant until they are synchronized to the new one
+	next.setFailing(true)
+	update(t, r, mr, func() { mr.Spec.URL = next.URL })
+	mr = runReconcile(t, r, "rules")
+
+	if mr.Status.Status == "Synced" {
+		t.Fatalf("expected the synchronization to the failing tenant to fail")
+	}
+	if requests := previous.takeRequests(); len(requests) != 0 {
+		t.Errorf("expected nothing to be deleted from the previous tenant, got %v", requests)
+	}
+	if len(mr.Status.PreviousTargets) != 1 || !slices.Equal(mr.Status.PreviousTargets[0].ManagedNamespaces, []string{"default_a"}) {
+		t.Fatalf("expected the previous tenant to be kept, got %+v", mr.Status.PreviousTargets)
+	}
+
+	// Once the new tenant recovers, the previous one is cleaned up
+	next.setFailing(false)
+	mr = runReconcile(t, r, "rules")
+
+	if names := previous.namespaceNames(); len(names) != 0 {
+		t.Errorf("expected the rules to be deleted from the previous tenant, got %v", names)
+	}
+	if len(mr.Status.PreviousTargets) != 0 {
+		t.Errorf("expected the previous tenant to be forgotten, got %+v", mr.Status.PreviousTargets)
+	}
+}
+
+func TestMigrationOrphansRules(t *testing.T) {
+	previous, next := newFakeRuler(t), newFakeRuler(t)
+	mr := newMimirRules("rules", previous.URL, map[string]string{"team": "a"})
+	mr.Spec.DeletionPolicy = domain.DeletionPolicyOrphan
+	r := newTestReconciler(t, mr, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+
+	runReconcile(t, r, "rules")
+
+	update(t, r, mr, func() { mr.Spec.URL = next.URL })
+	mr = runReconcile(t, r, "rules")
+
+	if names := next.namespaceNames(); !slices.Equal(names, []string{"default_a"}) {
+		t.Errorf("expected the rules to be pushed to the new tenant, got %v", names)
+	}
+	if names := previous.namespaceNames(); !slices.Equal(names, []string{"default_a"}) {
+		t.Errorf("expected the rules to be left in the previous tenant, got %v", names)
+	}
+	if len(mr.Status.PreviousTargets) != 0 {
+		t.Errorf("expected the previous tenant to be forgotten, got %+v", mr.Status.PreviousTargets)
+	}
+}
+
+func TestMigrationWithChangedCredentials(t *testing.T) {
+	previous, next := newFakeRuler(t), newFakeRuler(t)
+	mr := newMimirRules("rules", previous.URL, map[string]string{"team": "a"})
+	mr.Spec.Auth = &domain.Auth{TokenSecretRef: &domain.SecretKeySelector{Name: "previous"}}
+	r := newTestReconciler(t, mr, newPrometheusRule("a", map[string]string{"team": "a"}, "A"),
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "previous"}, Data: map[string][]byte{"token": []byte("previous-token")}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "next"}, Data: map[string][]byte{"token": []byte("next-token")}},
+	)
+
+	mr = runReconcile(t, r, "rules")
+	if mr.Status.Status != "Synced" {
+		t.Fatalf("expected the rules to be synced, got %s: %s", mr.Status.Status, mr.Status.Error)
+	}
+	if mr.Status.AppliedTarget == nil || mr.Status.AppliedTarget.Fingerprint == "" {
+		t.Errorf("expected the fingerprint of the settings to be recorded, got %+v", mr.Status.AppliedTarget)
+	}
+
+	// The new credentials are never sent to the previous tenant, whose rules are left as is
+	update(t, r, mr, func() {
+		mr.Spec.URL = next.URL
+		mr.Spec.Auth = &domain.Auth{TokenSecretRef: &domain.SecretKeySelector{Name: "next"}}
+	})
+	previous.takeRequests()
+	mr = runReconcile(t, r, "rules")
+
+	if mr.Status.Status != "Synced" {
+		t.Fatalf("expected the rules to be synced, got %s: %s", mr.Status.Status, mr.Status.Error)
+	}
+	if requests := previous.takeRequests(); len(requests) != 0 {
+		t.Errorf("expected the previous tenant not to be reached, got %v", requests)
+	}
+	if names := previous.namespaceNames(); !slices.Equal(names, []string{"default_a"}) {
+		t.Errorf("expected the rules to be left in the previous tenant, got %v", names)
+	}
+	if len(mr.Status.PreviousTargets) != 0 {
+		t.Errorf("expected the previous tenant to be forgotten, got %+v", mr.Status.PreviousTargets)
+	}
+}
@@ -2,23 +2,31 @@ package mimirrules
 
 import (
 	"context"
-	"fmt"
 	"slices"
+	"strings"
+	"time"
 
 	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
 	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirconnection"
+	"github.com/AmiditeX/mimir-operator/internal/metrics"
 	"github.com/AmiditeX/mimir-operator/internal/utils"
 )
 
@@ -30,14 +38,36 @@ const (
 // MimirRulesReconciler reconciles a MimirRules object
 type MimirRulesReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme      *runtime.Scheme
+	Connections *mimirconnection.Resolver
+	Recorder    record.EventRecorder
+
+	// ResyncInterval is how often a MimirRules is synchronized again to find changes made in Mimir, 0 disables it
+	ResyncInterval time.Duration
+
+	// DriftRepair is whether changes made in Mimir outside the operator are overwritten
+	DriftRepair bool
+
+	// DefaultDeletionPolicy is the deletion policy of the MimirRules that don't set one
+	DefaultDeletionPolicy string
+
+	// DryRun is whether the changes of every MimirRules are only planned, nothing being written to Mimir
+	DryRun bool
+
+	// changed holds the PrometheusRules that changed since the last synchronization of each MimirRules
+	changed changedRules
+
+	// selectors holds the parsed selectors of the MimirRules
+	selectors selectorCache
 }
 
 //+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimirrules,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimirrules/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=mimir.randgen.xyz,resources=mimirrules/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -46,13 +76,29 @@ func (r *MimirRulesReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	mr := &domain.MimirRules{}
 	err := r.Get(ctx, req.NamespacedName, mr)
 	if err != nil {
+		if errors.IsNotFound(err) {
+			// The MimirRules is gone, forget what was kept in memory for it
+			r.changed.take(req.NamespacedName)
+			r.selectors.forget(req.NamespacedName)
+		}
 		return reconcile.Result{}, client.IgnoreNotFound(err)
 	}
 
 	log.FromContext(ctx).Info("Running reconcile on MimirRules")
 
+	// Nothing is sent to Mimir while the MimirRules is suspended, not even when it is deleted
+	if mr.Spec.Suspend {
+		if !mr.DeletionTimestamp.IsZero() {
+			return ctrl.Result{}, r.releaseSuspended(ctx, mr)
+		}
+		return ctrl.Result{}, r.setSuspended(ctx, mr)
+	}
+
+	// Mimir doesn't need to be reached to release a MimirRules whose rules are orphaned, or left as is in dry-run mode
+	orphaning := !mr.DeletionTimestamp.IsZero() && (r.deletionPolicy(mr) == domain.DeletionPolicyOrphan || r.dryRun(mr))
+
 	mc, err := r.createMimirClient(ctx, mr)
-	if err != nil {
+	if err != nil && !orphaning {
 		// Update status with an error if we can't create a client for Mimir Api
 		return ctrl.Result{}, r.setStatus(ctx, mr, err)
 	}
@@ -68,159 +114,349 @@ func (r *MimirRulesReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 				return ctrl.Result{}, err
 			}
 		}
-	} else {
+	}
+
+	// The target is recorded once the finalizer is added, as the update of the MimirRules resets its status
+	if mc != nil {
+		r.trackTarget(ctx, mr, mc.Target())
+	}
+
+	if !mr.ObjectMeta.DeletionTimestamp.IsZero() {
 		// The object is being deleted
 		if controllerutil.ContainsFinalizer(mr, mimirFinalizer) {
 			if err := r.handleDeletion(ctx, mr, mc); err != nil {
 				// Status is set only on failure to delete (the status is going to be deleted anyway if it succeeds)
-				return ctrl.Result{}, r.setStatus(ctx, mr, err)
+				return mimirconnection.RequeueOnDegraded(err), r.setStatus(ctx, mr, err)
 			}
 
+			metrics.Forget(domain.MimirRulesKind, mr.Namespace, mr.Name)
+
 			// Remove our finalizer from the list and update it
 			controllerutil.RemoveFinalizer(mr, mimirFinalizer)
 			return ctrl.Result{}, r.Update(ctx, mr)
 		}
 	}
 
-	return ctrl.Result{}, r.handleCreationAndChanges(ctx, mr, mc)
+	return r.handleCreationAndChanges(ctx, mr, mc)
 }
 
 func (r *MimirRulesReconciler) createMimirClient(ctx context.Context, mr *domain.MimirRules) (*mimirapi.MimirClient, error) {
-	auth, err := utils.ExtractAuth(ctx, r.Client, mr.Spec.Auth, mr.ObjectMeta.Namespace)
-	if err != nil {
-		return nil, fmt.Errorf("failed to extract authentication settings: %w", err)
-	}
+	return r.Connections.NewMimirClient(ctx, r.target(mr))
+}
 
-	c, err := mimirapi.New(mimirapi.Config{
-		User:      auth.Username,
-		Key:       auth.Key,
-		AuthToken: auth.Token,
-		Address:   mr.Spec.URL,
-		ID:        mr.Spec.ID,
-	})
+// target returns the tenant a MimirRules is synchronized to
+func (r *MimirRulesReconciler) target(mr *domain.MimirRules) mimirconnection.Target {
+	return mimirconnection.Target{
+		Kind:          domain.MimirRulesKind,
+		ID:            mr.Spec.ID,
+		URL:           mr.Spec.URL,
+		Auth:          mr.Spec.Auth,
+		ConnectionRef: mr.Spec.ConnectionRef,
+		Headers:       mr.Spec.Headers,
+		Namespace:     mr.Namespace,
+	}
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create mimir client: %w", err)
+// deletionPolicy returns whether the rules of a MimirRules are deleted from Mimir or orphaned
+func (r *MimirRulesReconciler) deletionPolicy(mr *domain.MimirRules) string {
+	if mr.Spec.DeletionPolicy != "" {
+		return mr.Spec.DeletionPolicy
 	}
 
-	return c, nil
+	return r.DefaultDeletionPolicy
 }
 
 // handleCreationAndChanges handles reconciliation of MimirRules for events that are not a deletion
 // This means that this function will be called for any modification in a MimirRules or for
 // any creation of a new MimirRules in the API. It is also called periodically for scheduled
 // reconciliation and at the startup of the controller.
-func (r *MimirRulesReconciler) handleCreationAndChanges(ctx context.Context, mr *domain.MimirRules, mc *mimirapi.MimirClient) error {
+func (r *MimirRulesReconciler) handleCreationAndChanges(ctx context.Context, mr *domain.MimirRules, mc *mimirapi.MimirClient) (ctrl.Result, error) {
 	reconciliationError := r.reconcileRules(ctx, mr, mc)
+
+	// The rules are deleted from the tenants targeted before only once they are fully synchronized to the new one,
+	// so that switching to a failing tenant never leaves the rules missing from both. Until then the previous
+	// tenants are kept in the status. Cleanup failures are reported by the Migrating condition without
+	// failing the synchronization
+	if reconciliationError == nil && !r.dryRun(mr) {
+		_ = r.cleanupPreviousTargets(ctx, mr)
+	}
+
 	if err := r.setStatus(ctx, mr, reconciliationError); err != nil {
-		return err
+		return ctrl.Result{}, err
 	}
 
-	log.FromContext(ctx).Info("MimirRules correctly synchronized")
+	// Mimir refuses requests while it is degraded, try again once the circuit breaker lets requests through
+	if result := mimirconnection.RequeueOnDegraded(reconciliationError); !result.IsZero() {
+		log.FromContext(ctx).Info("MimirRules synchronization postponed until Mimir recovers", "requeueAfter", result.RequeueAfter)
+		return result, nil
+	}
+
+	if reconciliationError == nil {
+		log.FromContext(ctx).Info("MimirRules correctly synchronized")
+	}
 
-	return nil
+	// Synchronize again later to find and repair changes made in Mimir outside the operator
+	return mimirconnection.RequeueForResync(r.ResyncInterval), nil
 }
 
 // handleDeletion handles cleaning up after the deletion of a MimirRules
 func (r *MimirRulesReconciler) handleDeletion(ctx context.Context, mr *domain.MimirRules, mc *mimirapi.MimirClient) error {
 	log.FromContext(ctx).Info("Running reconciliation on deletion of a MimirRules")
-	return r.deleteRulesForTenant(ctx, mr, mc)
+
+	if r.dryRun(mr) {
+		r.Recorder.Eventf(mr, corev1.EventTypeNormal, domain.ReasonDryRun, "Dry run: would delete %d namespaces [%s]", len(managedNamespaces(mr)), strings.Join(managedNamespaces(mr), ", "))
+		return nil
+	}
+
+	if r.deletionPolicy(mr) == domain.DeletionPolicyOrphan {
+		r.Recorder.Eventf(mr, corev1.EventTypeNormal, "RulesOrphaned", "Left the rules of tenant %s in Mimir: %s", mr.Spec.ID, strings.Join(managedNamespaces(mr), ", "))
+		return r.cleanupPreviousTargets(ctx, mr)
+	}
+
+	if err := r.deleteRulesForTenant(ctx, mr, mc); err != nil {
+		return err
+	}
+
+	return r.cleanupPreviousTargets(ctx, mr)
 }
 
 // reconcileRules ensures Mimir is synced with the PrometheusRules associated with a MimirRules
+// When the reconciliation was caused by changes to PrometheusRules, only their namespaces are synchronized
+// if possible. Otherwise, such as for changes of the MimirRules and periodic resyncs, every namespace is.
 func (r *MimirRulesReconciler) reconcileRules(ctx context.Context, mr *domain.MimirRules, mc *mimirapi.MimirClient) error {
+	changed := r.changed.take(client.ObjectKeyFromObject(mr))
+
+	if r.dryRun(mr) {
+		log.FromContext(ctx).Info("Planning the changes of the rules in dry-run mode")
+		return r.planRules(ctx, mc, mr)
+	}
+
+	if len(changed) > 0 && canSyncIncrementally(mr) {
+		log.FromContext(ctx).Info("Running reconciliation of the changed rules", "prometheusRules", len(changed))
+		return r.syncChangedRules(ctx, mc, mr, changed)
+	}
+
 	log.FromContext(ctx).Info("Running reconciliation of the rules")
 
 	return r.syncRulesToRuler(ctx, mc, mr)
 }
 
-// reconcileOnPrometheusRuleChange sends a reconcile request to EVERY MimirRule on the cluster
-// This is done to retrigger the synchronization of MimirRules if new PrometheusRules have been added
-// or if some PrometheusRules have changed their definition
+// reconcileOnPrometheusRuleChange sends a reconcile request to the MimirRules affected by a change of a PrometheusRule
+// Those are the MimirRules selecting the PrometheusRule, and those which synchronized it earlier, as it might have
+// been deleted or not be selected anymore (for example if its labels changed)
+// Only the Mimir namespace of the PrometheusRule is then synchronized by those MimirRules when possible
 func (r *MimirRulesReconciler) reconcileOnPrometheusRuleChange(ctx context.Context, rule client.Object) []reconcile.Request {
-	allMimirRules := &domain.MimirRulesList{}
-	err := r.List(context.Background(), allMimirRules)
-	if err != nil {
-		log.FromContext(context.TODO()).Error(err, "failed to list all MimirRules after a PrometheusRule change")
-		return []reconcile.Request{}
-	}
-
-	// Check for existance of the rule
-	pr := &prometheus.PrometheusRule{}
-	err = r.Get(ctx, types.NamespacedName{Namespace: rule.GetNamespace(), Name: rule.GetName()}, pr)
-
-	requests := make([]reconcile.Request, 0)
-	// If Prometheus Rule is not found then it must have been deleted
-	if err != nil && errors.IsNotFound(err) {
-		// We update every MimirRule concerned by the delete Prometheus Rule
-		for _, item := range allMimirRules.Items {
-			namespaceAndName := rule.GetNamespace() + "_" + rule.GetName()
-
-			if slices.Contains(item.Status.RefRules, namespaceAndName) {
-				requests = append(requests, reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Name:      item.GetName(),
-						Namespace: item.GetNamespace(),
-					}})
-			}
+	ruleKey := client.ObjectKeyFromObject(rule)
+
+	// MimirRules are only read, they don't need to be copied out of the cache
+	referencing := &domain.MimirRulesList{}
+	if err := r.List(ctx, referencing, client.MatchingFields{prometheusRuleRefIndex: rule.GetNamespace() + "_" + rule.GetName()}, client.UnsafeDisableDeepCopy); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list the MimirRules referencing a PrometheusRule after it changed")
+		return nil
+	}
+
+	// Only the MimirRules requiring one of the label keys of the PrometheusRule, or no key at all, might select it
+	keys := []string{anyLabelKey}
+	for key := range rule.GetLabels() {
+		keys = append(keys, key)
+	}
+
+	affected := referencing.Items
+	for _, key := range keys {
+		candidates := &domain.MimirRulesList{}
+		if err := r.List(ctx, candidates, client.MatchingFields{selectorKeyIndex: key}, client.UnsafeDisableDeepCopy); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list the MimirRules selecting a label after a PrometheusRule change", "label", key)
+			return nil
 		}
-	} else { // Prometheus rule have been updated or created, update only concerned rules
-		for _, item := range allMimirRules.Items {
-			promRulesList, _ := r.findPrometheusRulesFromLabels(ctx, item.Spec.Rules.Selectors)
-			namespaceAndName := rule.GetNamespace() + "_" + rule.GetName()
-			// If the updated/created rule affect the MimirRule then we request a reconcialition on it
-			// We check if the MimirRule match with the Prometheus Rule
-			// But also if the MimirRule previously match the Prometheus Rule (for example if the group change for the rule and not matching anymore)
-			if contains(promRulesList, rule.GetNamespace(), rule.GetName()) || slices.Contains(item.Status.RefRules, namespaceAndName) {
-				requests = append(requests, reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Name:      item.GetName(),
-						Namespace: item.GetNamespace(),
-					}})
+
+		for _, item := range candidates.Items {
+			if r.selectors.matches(&item, rule.GetLabels()) {
+				affected = append(affected, item)
 			}
 		}
 	}
 
+	var requests []reconcile.Request
+	for _, item := range affected {
+		key := client.ObjectKeyFromObject(&item)
+		r.changed.add(key, ruleKey)
+
+		request := reconcile.Request{NamespacedName: key}
+		if !slices.Contains(requests, request) {
+			requests = append(requests, request)
+		}
+	}
+
 	return requests
 }
 
 // setStatus updates the status of MimirRules after reconciliation
 // If err is not nil, the error field is populated with the error and the status is set as "Failed"
-// Otherwise, status is set as "Synced"
+// If the error was caused by an open circuit breaker on the Mimir endpoint, the status is set as "Degraded" instead
+// Otherwise, status is set as "Synced", or as "DryRun" if the changes were only planned
+// The conditions of the status are updated accordingly, and a Warning event is emitted on failure
 func (r *MimirRulesReconciler) setStatus(ctx context.Context, mr *domain.MimirRules, err error) error {
-	if err != nil {
+	if _, degraded := mimirapi.CircuitOpenDelay(err); degraded {
+		mr.Status.Status = "Degraded"
+		mr.Status.Error = err.Error()
+	} else if err != nil {
 		mr.Status.Status = "Failed"
 		mr.Status.Error = err.Error()
 
 		// Also log the error in the controller for clarity
 		log.FromContext(ctx).Error(err, "Failed to reconcile MimirRules")
+	} else if r.dryRun(mr) {
+		mr.Status.Status = "DryRun"
+		mr.Status.Error = ""
 	} else {
 		mr.Status.Status = "Synced"
 		mr.Status.Error = ""
+		mr.Status.LastSyncTime = &metav1.Time{Time: time.Now()}
+	}
+
+	// The plan is only kept while it describes the changes the MimirRules would make
+	plan := ""
+	if err != nil || !r.dryRun(mr) {
+		mr.Status.Plan = nil
+	} else if mr.Status.Plan != nil {
+		plan = describePlan(mr.Status.Plan)
+	}
+
+	if err != nil {
+		r.Recorder.Event(mr, corev1.EventTypeWarning, utils.SyncReason(err), err.Error())
+	}
+
+	mr.Status.ObservedGeneration = mr.Generation
+	utils.SetSyncConditions(&mr.Status.Conditions, mr.Generation, err)
+	utils.SetSuspendedCondition(&mr.Status.Conditions, mr.Generation, "")
+	utils.SetDryRunCondition(&mr.Status.Conditions, mr.Generation, plan)
+
+	metrics.SetSyncStatus(domain.MimirRulesKind, mr.Namespace, mr.Name, mr.Spec.ID, mr.Status.Status)
+
+	return r.Status().Update(context.Background(), mr)
+}
+
+// setSuspended updates the status of a suspended MimirRules
+// The status is only updated when it changes, as the MimirRules is still reconciled when its dependencies change
+func (r *MimirRulesReconciler) setSuspended(ctx context.Context, mr *domain.MimirRules) error {
+	message := utils.SuspendedMessage
+
+	condition := meta.FindStatusCondition(mr.Status.Conditions, domain.ConditionSuspended)
+	if mr.Status.Status == "Suspended" && condition != nil && condition.Status == metav1.ConditionTrue &&
+		condition.Message == message && condition.ObservedGeneration == mr.Generation {
+		return nil
 	}
 
+	log.FromContext(ctx).Info("MimirRules synchronization suspended")
+
+	mr.Status.Status = "Suspended"
+	mr.Status.Error = ""
+	mr.Status.ObservedGeneration = mr.Generation
+	utils.SetSuspendedCondition(&mr.Status.Conditions, mr.Generation, message)
+
+	metrics.SetSyncStatus(domain.MimirRulesKind, mr.Namespace, mr.Name, mr.Spec.ID, mr.Status.Status)
+	r.Recorder.Event(mr, corev1.EventTypeNormal, domain.ReasonSuspended, message)
+
 	return r.Status().Update(context.Background(), mr)
 }
 
+// releaseSuspended removes the finalizer of a MimirRules deleted while it is suspended
+// Its rules is left in Mimir, as with the Orphan deletion policy: resuming a resource being deleted isn't possible
+func (r *MimirRulesReconciler) releaseSuspended(ctx context.Context, mr *domain.MimirRules) error {
+	if !controllerutil.ContainsFinalizer(mr, mimirFinalizer) {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Suspended MimirRules deleted, leaving its rules in Mimir")
+	r.Recorder.Event(mr, corev1.EventTypeWarning, domain.ReasonOrphaned, utils.SuspendedDeletionMessage)
+	metrics.Forget(domain.MimirRulesKind, mr.Namespace, mr.Name)
+
+	controllerutil.RemoveFinalizer(mr, mimirFinalizer)
+	return r.Update(ctx, mr)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *MimirRulesReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&domain.MimirRules{}).
+	// Index MimirRules by the PrometheusRules they synchronized, so they can be found when one of those changes
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &domain.MimirRules{}, prometheusRuleRefIndex, func(o client.Object) []string {
+		return o.(*domain.MimirRules).Status.RefRules
+	})
+	if err != nil {
+		return err
+	}
+
+	// Index MimirRules by the label keys their selectors require, so that only the MimirRules that might select
+	// a PrometheusRule are matched against it when it changes
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &domain.MimirRules{}, selectorKeyIndex, func(o client.Object) []string {
+		return selectorKeys(o.(*domain.MimirRules))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Index MimirRules by their target, so that the MimirRules sharing a tenant can be found
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &domain.MimirRules{}, targetIndex, indexByTarget)
+	if err != nil {
+		return err
+	}
+
+	// Index MimirRules by the connection they reference, so they can be found when that connection changes
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &domain.MimirRules{}, mimirconnection.ConnectionRefIndex, func(o client.Object) []string {
+		mr := o.(*domain.MimirRules)
+		if value := mimirconnection.RefIndexValue(mr.Namespace, mr.Spec.ConnectionRef); value != "" {
+			return []string{value}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Index MimirRules by the Secrets and ConfigMaps they reference, so they can be found when one of them changes
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &domain.MimirRules{}, mimirconnection.ReferenceIndex, func(o client.Object) []string {
+		mr := o.(*domain.MimirRules)
+		return mimirconnection.ReferenceIndexValues(mr.Namespace, mr.Spec.Auth, mr.Spec.Headers)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Synchronize again when the settings of the Mimir instance referenced through a connection change
+	reconcileOnConnectionChange := handler.EnqueueRequestsFromMapFunc(mimirconnection.EnqueueDependents(r.Client, func() client.ObjectList {
+		return &domain.MimirRulesList{}
+	}))
+
+	// Synchronize again when a referenced Secret or ConfigMap changes, or when a grant allowing a cross-namespace reference changes
+	reconcileOnReferenceChange := mimirconnection.EnqueueReferenceDependents(r.Client, func() client.ObjectList {
+		return &domain.MimirRulesList{}
+	})
+
+	// Status updates are ignored, as every synchronization updates the status and would trigger another one
+	// The MimirRules sharing the tenant of a MimirRules are synchronized again when it changes, as they might
+	// have to take over or give up some of its rules
+	// The ConfigMaps holding the rendered rules are watched to restore them as soon as they are edited or deleted
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&domain.MimirRules{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Owns(&corev1.ConfigMap{}).
 		Watches( // Setup WATCH on PrometheusRules to dynamically reload MimirRules into the MimirRuler if a selected rule has been changed
 			&prometheus.PrometheusRule{},
 			handler.EnqueueRequestsFromMapFunc(r.reconcileOnPrometheusRuleChange)).
+		Watches(
+			&domain.MimirRules{},
+			handler.EnqueueRequestsFromMapFunc(r.reconcileOnRivalChange),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, targetChangedPredicate))).
+		Watches(
+			&domain.MimirConnection{},
+			reconcileOnConnectionChange,
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(
+			&domain.ClusterMimirConnection{},
+			reconcileOnConnectionChange,
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	return utils.WatchReferences(b, r.Connections.References, reconcileOnReferenceChange).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 32,
 		}).
 		Complete(r)
 }
-
-// Check if the prometheus rule list has a matching rule with namespace and name combinaison
-func contains(pL *prometheus.PrometheusRuleList, namespace, name string) bool {
-	for _, p := range pL.Items {
-		if p.Name == name && p.Namespace == namespace {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,335 @@
+package mimirrules
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi/rwrulefmt"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirconnection"
+)
+
+// rulerPath is the path of the ruler API of Mimir
+const rulerPath = "/prometheus/config/v1/rules"
+
+// fakeRuler is a Mimir ruler API holding the rule groups of a tenant in memory
+type fakeRuler struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	namespaces map[string][]rwrulefmt.RuleGroup
+	requests   []string
+	failing    bool
+	rejected   map[string]bool
+}
+
+func newFakeRuler(t *testing.T) *fakeRuler {
+	ruler := &fakeRuler{namespaces: map[string][]rwrulefmt.RuleGroup{}, rejected: map[string]bool{}}
+	ruler.Server = httptest.NewServer(http.HandlerFunc(ruler.serve))
+	t.Cleanup(ruler.Close)
+
+	return ruler
+}
+
+func (f *fakeRuler) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if f.failing {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	namespace := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, rulerPath), "/")
+	switch r.Method {
+	case http.MethodGet:
+		body, err := yaml.Marshal(f.namespaces)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(body)
+	case http.MethodPost:
+		if f.rejected[namespace] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var group rwrulefmt.RuleGroup
+		if err := yaml.NewDecoder(r.Body).Decode(&group); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		groups := slices.DeleteFunc(f.namespaces[namespace], func(g rwrulefmt.RuleGroup) bool { return g.Name == group.Name })
+		f.namespaces[namespace] = append(groups, group)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodDelete:
+		if _, ok := f.namespaces[namespace]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.namespaces, namespace)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// namespaceNames returns the sorted names of the namespaces of the tenant
+func (f *fakeRuler) namespaceNames() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	names := make([]string, 0, len(f.namespaces))
+	for namespace := range f.namespaces {
+		names = append(names, namespace)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// groups returns the rule groups of a namespace of the tenant
+func (f *fakeRuler) groups(namespace string) []rwrulefmt.RuleGroup {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.namespaces[namespace]
+}
+
+// takeRequests returns the requests received since the last call, as "<method> <namespace>"
+func (f *fakeRuler) takeRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	requests := make([]string, 0, len(f.requests))
+	for _, request := range f.requests {
+		requests = append(requests, strings.TrimSuffix(strings.Replace(request, rulerPath, "", 1), " "))
+	}
+	f.requests = nil
+
+	return requests
+}
+
+func (f *fakeRuler) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failing = failing
+}
+
+// setRejected makes the ruler reject the rule groups pushed to a namespace
+func (f *fakeRuler) setRejected(namespace string, rejected bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rejected[namespace] = rejected
+}
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, domain.AddToScheme, prometheus.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatalf("failed to build scheme: %v", err)
+		}
+	}
+
+	return scheme
+}
+
+// newTestReconciler returns a reconciler working on a fake client holding the given objects, with the indexes
+// of the controller
+func newTestReconciler(t *testing.T, objs ...client.Object) *MimirRulesReconciler {
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&domain.MimirRules{}).
+		WithIndex(&domain.MimirRules{}, targetIndex, indexByTarget).
+		WithIndex(&domain.MimirRules{}, selectorKeyIndex, func(o client.Object) []string {
+			return selectorKeys(o.(*domain.MimirRules))
+		}).
+		WithIndex(&domain.MimirRules{}, prometheusRuleRefIndex, func(o client.Object) []string {
+			return o.(*domain.MimirRules).Status.RefRules
+		}).
+		Build()
+
+	return &MimirRulesReconciler{
+		Client:                c,
+		Scheme:                scheme,
+		Connections:           &mimirconnection.Resolver{Client: c},
+		Recorder:              &record.FakeRecorder{},
+		DriftRepair:           true,
+		DefaultDeletionPolicy: domain.DeletionPolicyDelete,
+	}
+}
+
+func newMimirRules(name, url string, selector map[string]string) *domain.MimirRules {
+	return &domain.MimirRules{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		Spec: domain.MimirRulesSpec{
+			ID:    "tenant",
+			URL:   url,
+			Rules: &domain.Rules{Selectors: []*metav1.LabelSelector{{MatchLabels: selector}}},
+		},
+	}
+}
+
+func newPrometheusRule(name string, labels map[string]string, alerts ...string) *prometheus.PrometheusRule {
+	rules := make([]prometheus.Rule, 0, len(alerts))
+	for _, alert := range alerts {
+		rules = append(rules, prometheus.Rule{Alert: alert, Expr: intstr.FromString("up == 0")})
+	}
+
+	return &prometheus.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec:       prometheus.PrometheusRuleSpec{Groups: []prometheus.RuleGroup{{Name: name, Rules: rules}}},
+	}
+}
+
+// runReconcile runs a reconciliation of a MimirRules and returns it once reconciled
+func runReconcile(t *testing.T, r *MimirRulesReconciler, name string) *domain.MimirRules {
+	t.Helper()
+
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile %s: %v", name, err)
+	}
+
+	mr := &domain.MimirRules{}
+	if err := r.Get(context.Background(), key, mr); err != nil {
+		t.Fatalf("failed to get %s: %v", name, err)
+	}
+
+	return mr
+}
+
+// update changes an object with the fake client
+func update(t *testing.T, r *MimirRulesReconciler, obj client.Object, mutate func()) {
+	t.Helper()
+
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(obj), obj); err != nil {
+		t.Fatalf("failed to get %s: %v", obj.GetName(), err)
+	}
+	mutate()
+	if err := r.Update(context.Background(), obj); err != nil {
+		t.Fatalf("failed to update %s: %v", obj.GetName(), err)
+	}
+}
+
+func TestReconcileSynchronizesSelectedRules(t *testing.T) {
+	ruler := newFakeRuler(t)
+	r := newTestReconciler(t,
+		newMimirRules("rules", ruler.URL, map[string]string{"team": "a"}),
+		newPrometheusRule("a", map[string]string{"team": "a"}, "A"),
+		newPrometheusRule("b", map[string]string{"team": "b"}, "B"),
+	)
+
+	mr := runReconcile(t, r, "rules")
+
+	if mr.Status.Status != "Synced" {
+		t.Fatalf("expected the rules to be synced, got %s: %s", mr.Status.Status, mr.Status.Error)
+	}
+	if names := ruler.namespaceNames(); !slices.Equal(names, []string{"default_a"}) {
+		t.Errorf("expected only the selected rules in Mimir, got %v", names)
+	}
+	if !slices.Equal(mr.Status.ManagedNamespaces, []string{"default_a"}) {
+		t.Errorf("expected the pushed namespace to be managed, got %v", mr.Status.ManagedNamespaces)
+	}
+}
+
+func TestDeletionPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy        string
+		defaultPolicy string
+		namespaces    []string
+	}{
+		"delete":         {policy: domain.DeletionPolicyDelete, defaultPolicy: domain.DeletionPolicyOrphan, namespaces: []string{}},
+		"orphan":         {policy: domain.DeletionPolicyOrphan, defaultPolicy: domain.DeletionPolicyDelete, namespaces: []string{"default_a"}},
+		"default delete": {defaultPolicy: domain.DeletionPolicyDelete, namespaces: []string{}},
+		"default orphan": {defaultPolicy: domain.DeletionPolicyOrphan, namespaces: []string{"default_a"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ruler := newFakeRuler(t)
+			mr := newMimirRules("rules", ruler.URL, map[string]string{"team": "a"})
+			mr.Spec.DeletionPolicy = test.policy
+			r := newTestReconciler(t, mr, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+			r.DefaultDeletionPolicy = test.defaultPolicy
+
+			runReconcile(t, r, "rules")
+
+			if err := r.Delete(context.Background(), mr); err != nil {
+				t.Fatalf("failed to delete the MimirRules: %v", err)
+			}
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(mr)}); err != nil {
+				t.Fatalf("failed to reconcile the deletion: %v", err)
+			}
+
+			if names := ruler.namespaceNames(); !slices.Equal(names, test.namespaces) {
+				t.Errorf("expected the namespaces %v to be left in Mimir, got %v", test.namespaces, names)
+			}
+			if err := r.Get(context.Background(), client.ObjectKeyFromObject(mr), mr); err == nil {
+				t.Errorf("expected the MimirRules to be released")
+			}
+		})
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	ruler := newFakeRuler(t)
+	mr := newMimirRules("rules", ruler.URL, map[string]string{"team": "a"})
+	r := newTestReconciler(t, mr, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+
+	runReconcile(t, r, "rules")
+	ruler.takeRequests()
+
+	update(t, r, mr, func() { mr.Spec.Suspend = true })
+	mr = runReconcile(t, r, "rules")
+
+	if requests := ruler.takeRequests(); len(requests) != 0 {
+		t.Errorf("expected nothing to be sent to Mimir, got %v", requests)
+	}
+	if mr.Status.Status != "Suspended" || !meta.IsStatusConditionTrue(mr.Status.Conditions, domain.ConditionSuspended) {
+		t.Errorf("expected the MimirRules to be reported as suspended, got %s and %+v", mr.Status.Status, mr.Status.Conditions)
+	}
+
+	// The rules of a suspended MimirRules are left in Mimir when it is deleted, without waiting for it to be resumed
+	if err := r.Delete(context.Background(), mr); err != nil {
+		t.Fatalf("failed to delete the MimirRules: %v", err)
+	}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(mr)}); err != nil {
+		t.Fatalf("failed to reconcile the deletion: %v", err)
+	}
+
+	if requests := ruler.takeRequests(); len(requests) != 0 {
+		t.Errorf("expected nothing to be sent to Mimir, got %v", requests)
+	}
+	if names := ruler.namespaceNames(); !slices.Equal(names, []string{"default_a"}) {
+		t.Errorf("expected the rules to be left in Mimir, got %v", names)
+	}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(mr), mr); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the suspended MimirRules to be released, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package mimirrules
+
+import (
+	"slices"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// managedNamespaces returns the Mimir namespaces created by a MimirRules, which are the only ones it may delete
+// MimirRules synchronized before those were recorded own the namespaces of the PrometheusRules they last synchronized
+func managedNamespaces(mr *domain.MimirRules) []string {
+	if mr.Status.ManagedNamespacesRecorded {
+		return mr.Status.ManagedNamespaces
+	}
+
+	return slices.Clone(mr.Status.RefRules)
+}
+
+// recordManagedNamespaces records the Mimir namespaces created by a MimirRules
+func recordManagedNamespaces(mr *domain.MimirRules, namespaces []string) {
+	mr.Status.ManagedNamespaces = namespaces
+	mr.Status.ManagedNamespacesRecorded = true
+}
+
+// addManagedNamespace records that a Mimir namespace was created by a MimirRules
+func addManagedNamespace(mr *domain.MimirRules, namespace string) {
+	namespaces := managedNamespaces(mr)
+	if !slices.Contains(namespaces, namespace) {
+		namespaces = append(namespaces, namespace)
+		slices.Sort(namespaces)
+	}
+
+	recordManagedNamespaces(mr, namespaces)
+}
+
+// removeManagedNamespace records that a Mimir namespace created by a MimirRules was deleted
+func removeManagedNamespace(mr *domain.MimirRules, namespace string) {
+	recordManagedNamespaces(mr, slices.DeleteFunc(managedNamespaces(mr), func(managed string) bool {
+		return managed == namespace
+	}))
+}
@@ -0,0 +1,47 @@
+package mimirrules
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi/rwrulefmt"
+)
+
+func TestOwnership(t *testing.T) {
+	tests := map[string]struct {
+		recorded bool
+		managed  []string
+		expected []string
+	}{
+		// MimirRules last synchronized by older versions of the operator own the namespaces of their PrometheusRules
+		"not recorded": {expected: []string{"default_manual"}},
+		// An empty list of managed namespaces isn't mistaken for one that was never recorded
+		"recorded empty": {recorded: true, expected: []string{"default_manual", "default_other"}},
+		"recorded":       {recorded: true, managed: []string{"default_other"}, expected: []string{"default_manual"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ruler := newFakeRuler(t)
+			// default_manual was loaded by hand, and default_other was synchronized from a PrometheusRule now deleted
+			ruler.namespaces["default_manual"] = []rwrulefmt.RuleGroup{{}}
+			ruler.namespaces["default_other"] = []rwrulefmt.RuleGroup{{}}
+
+			mr := newMimirRules("rules", ruler.URL, map[string]string{"team": "a"})
+			mr.Finalizers = []string{mimirFinalizer}
+			mr.Status.RefRules = []string{"default_other"}
+			mr.Status.ManagedNamespaces = test.managed
+			mr.Status.ManagedNamespacesRecorded = test.recorded
+			r := newTestReconciler(t, mr)
+
+			mr = runReconcile(t, r, "rules")
+
+			if names := ruler.namespaceNames(); !slices.Equal(names, test.expected) {
+				t.Errorf("expected the namespaces %v to be left in Mimir, got %v", test.expected, names)
+			}
+			if !mr.Status.ManagedNamespacesRecorded || len(mr.Status.ManagedNamespaces) != 0 {
+				t.Errorf("expected an empty list of managed namespaces to be recorded, got %v", mr.Status.ManagedNamespaces)
+			}
+		})
+	}
+}
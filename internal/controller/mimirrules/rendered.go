@@ -0,0 +1,184 @@
+package mimirrules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+const (
+	// RenderedOutputLabel labels the ConfigMaps holding the rendered rules of a MimirRules with its name
+	// The cache of the manager only holds the ConfigMaps with this label
+	RenderedOutputLabel = "mimir.randgen.xyz/rendered-from"
+
+	// renderedOutputSize is the maximum size of the rules written to a single ConfigMap, below the 1MiB
+	// limit of Kubernetes objects to leave room for their metadata
+	renderedOutputSize = 900 * 1024
+
+	// renderedOutputSuffix is appended to the Mimir namespaces to name the keys of the ConfigMaps
+	renderedOutputSuffix = ".yaml"
+)
+
+// renderedOutputEnabled returns whether the rendered rules of a MimirRules are written to ConfigMaps
+func renderedOutputEnabled(mr *domain.MimirRules) bool {
+	return mr.Spec.RenderedOutput != nil && mr.Spec.RenderedOutput.Enabled
+}
+
+// renderedOutputName returns the prefix of the names of the ConfigMaps holding the rendered rules of a MimirRules
+func renderedOutputName(mr *domain.MimirRules) string {
+	if mr.Spec.RenderedOutput != nil && mr.Spec.RenderedOutput.Name != "" {
+		return mr.Spec.RenderedOutput.Name
+	}
+
+	return mr.Name + "-rendered"
+}
+
+// publishRenderedOutput writes the rendered rules of a MimirRules, by Mimir namespace, to the ConfigMaps it owns,
+// or deletes those ConfigMaps if the rendered output is disabled
+// Failures are only reported, as they don't prevent the rules from being synchronized
+func (r *MimirRulesReconciler) publishRenderedOutput(ctx context.Context, mr *domain.MimirRules, rendered map[string]string) {
+	if !renderedOutputEnabled(mr) {
+		rendered = nil
+	}
+
+	if err := r.writeRenderedOutput(ctx, mr, rendered); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to write the rendered rules of MimirRules")
+		r.Recorder.Eventf(mr, corev1.EventTypeWarning, "RenderedOutputFailed", "Failed to write the rendered rules: %s", err)
+	}
+}
+
+// updateRenderedOutput replaces the rendered rules of some Mimir namespaces in the ConfigMaps of a MimirRules,
+// the namespaces in removed being deleted from them
+func (r *MimirRulesReconciler) updateRenderedOutput(ctx context.Context, mr *domain.MimirRules, changed map[string]string, removed []string) {
+	if !renderedOutputEnabled(mr) {
+		return
+	}
+
+	configMaps, err := r.listRenderedOutput(ctx, mr)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to read the rendered rules of MimirRules")
+		r.Recorder.Eventf(mr, corev1.EventTypeWarning, "RenderedOutputFailed", "Failed to read the rendered rules: %s", err)
+		return
+	}
+
+	rendered := map[string]string{}
+	for _, configMap := range configMaps {
+		for key, content := range configMap.Data {
+			rendered[strings.TrimSuffix(key, renderedOutputSuffix)] = content
+		}
+	}
+	for namespace, content := range changed {
+		rendered[namespace] = content
+	}
+	for _, namespace := range removed {
+		delete(rendered, namespace)
+	}
+
+	r.publishRenderedOutput(ctx, mr, rendered)
+}
+
+// writeRenderedOutput writes rendered rules to the ConfigMaps of a MimirRules, deleting the ConfigMaps that are
+// not needed anymore
+func (r *MimirRulesReconciler) writeRenderedOutput(ctx context.Context, mr *domain.MimirRules, rendered map[string]string) error {
+	var chunks []map[string]string
+	if rendered != nil {
+		var err error
+		if chunks, err = splitRenderedOutput(rendered); err != nil {
+			return err
+		}
+	}
+
+	names := map[string]struct{}{}
+	for i, data := range chunks {
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", renderedOutputName(mr), i),
+			Namespace: mr.Namespace,
+		}}
+		names[configMap.Name] = struct{}{}
+
+		_, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+			if configMap.Labels == nil {
+				configMap.Labels = map[string]string{}
+			}
+			configMap.Labels[RenderedOutputLabel] = mr.Name
+			configMap.Data = data
+
+			return controllerutil.SetControllerReference(mr, configMap, r.Scheme)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	existing, err := r.listRenderedOutput(ctx, mr)
+	if err != nil {
+		return err
+	}
+	for _, configMap := range existing {
+		if _, ok := names[configMap.Name]; !ok {
+			if err := r.Delete(ctx, &configMap); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// listRenderedOutput returns the ConfigMaps holding the rendered rules of a MimirRules, sorted by name
+func (r *MimirRulesReconciler) listRenderedOutput(ctx context.Context, mr *domain.MimirRules) ([]corev1.ConfigMap, error) {
+	list := &corev1.ConfigMapList{}
+	if err := r.List(ctx, list, client.InNamespace(mr.Namespace), client.MatchingLabels{RenderedOutputLabel: mr.Name}); err != nil {
+		return nil, err
+	}
+
+	var configMaps []corev1.ConfigMap
+	for _, configMap := range list.Items {
+		// Only the ConfigMaps created for this MimirRules are read and deleted
+		if metav1.IsControlledBy(&configMap, mr) {
+			configMaps = append(configMaps, configMap)
+		}
+	}
+	sort.Slice(configMaps, func(i, j int) bool { return configMaps[i].Name < configMaps[j].Name })
+
+	return configMaps, nil
+}
+
+// splitRenderedOutput splits rendered rules, by Mimir namespace, into the data of ConfigMaps that fit
+// within renderedOutputSize, sorted by Mimir namespace
+// It always returns at least one chunk, so that a ConfigMap shows that no rules are rendered
+func splitRenderedOutput(rendered map[string]string) ([]map[string]string, error) {
+	namespaces := make([]string, 0, len(rendered))
+	for namespace := range rendered {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	chunks := []map[string]string{{}}
+	size := 0
+	for _, namespace := range namespaces {
+		key := namespace + renderedOutputSuffix
+		entrySize := len(key) + len(rendered[namespace])
+		if entrySize > renderedOutputSize {
+			return nil, fmt.Errorf("the rendered rules of namespace %s are larger than a ConfigMap can hold", namespace)
+		}
+
+		if size+entrySize > renderedOutputSize {
+			chunks = append(chunks, map[string]string{})
+			size = 0
+		}
+		chunks[len(chunks)-1][key] = rendered[namespace]
+		size += entrySize
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,100 @@
+package mimirrules
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestSplitRenderedOutput(t *testing.T) {
+	// large is sized for two of them to fit in a chunk, but not three
+	large := strings.Repeat("x", renderedOutputSize/3)
+
+	tests := map[string]struct {
+		rendered map[string]string
+		chunks   [][]string
+		invalid  bool
+	}{
+		"no rules": {
+			rendered: map[string]string{},
+			chunks:   [][]string{{}},
+		},
+		"single chunk": {
+			rendered: map[string]string{"b": "rules", "a": "rules"},
+			chunks:   [][]string{{"a.yaml", "b.yaml"}},
+		},
+		"several chunks": {
+			rendered: map[string]string{"c": large, "a": large, "b": large, "d": "rules"},
+			chunks:   [][]string{{"a.yaml", "b.yaml"}, {"c.yaml", "d.yaml"}},
+		},
+		"too large": {
+			rendered: map[string]string{"a": strings.Repeat("x", renderedOutputSize)},
+			invalid:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			chunks, err := splitRenderedOutput(test.rendered)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			keys := make([][]string, 0, len(chunks))
+			for _, chunk := range chunks {
+				chunkKeys := []string{}
+				size := 0
+				for key, content := range chunk {
+					chunkKeys = append(chunkKeys, key)
+					size += len(key) + len(content)
+				}
+				slices.Sort(chunkKeys)
+				keys = append(keys, chunkKeys)
+
+				if size > renderedOutputSize {
+					t.Errorf("expected the chunks to fit within %d bytes, got %d", renderedOutputSize, size)
+				}
+			}
+			if !slices.EqualFunc(keys, test.chunks, slices.Equal[[]string]) {
+				t.Errorf("expected chunks %v, got %v", test.chunks, keys)
+			}
+		})
+	}
+}
+
+func TestPublishRenderedOutput(t *testing.T) {
+	ruler := newFakeRuler(t)
+	mr := newMimirRules("rules", ruler.URL, map[string]string{"team": "a"})
+	mr.Spec.RenderedOutput = &domain.RenderedOutput{Enabled: true}
+	r := newTestReconciler(t, mr, newPrometheusRule("a", map[string]string{"team": "a"}, "A"))
+
+	runReconcile(t, r, "rules")
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "rules-rendered-0"}, configMap); err != nil {
+		t.Fatalf("expected the rendered rules to be written: %v", err)
+	}
+	if !strings.Contains(configMap.Data["default_a.yaml"], "alert: A") || configMap.Labels[RenderedOutputLabel] != "rules" {
+		t.Errorf("expected the rendered rules of the namespace, got %v and %v", configMap.Labels, configMap.Data)
+	}
+
+	// The ConfigMaps are deleted once the rendered output is disabled
+	update(t, r, mr, func() { mr.Spec.RenderedOutput.Enabled = false })
+	runReconcile(t, r, "rules")
+
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap); err == nil {
+		t.Errorf("expected the rendered rules to be deleted")
+	}
+}
@@ -0,0 +1,65 @@
+package mimirrules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// ruleResult returns the result of the synchronization of the rules of a PrometheusRule
+// err is the error returned when pushing its rules, and skipped whether they were left as is in Mimir
+func ruleResult(namespace, name string, err error, skipped bool) domain.RuleSyncResult {
+	result := domain.RuleSyncResult{Namespace: namespace, Name: name, State: domain.RuleSynced}
+
+	switch {
+	case err != nil:
+		result.State = domain.RuleRejected
+		result.Error = err.Error()
+	case skipped:
+		result.State = domain.RuleSkipped
+	}
+
+	return result
+}
+
+// conflictResult returns the result of the rules of a PrometheusRule synchronized by an older MimirRules, owner
+func conflictResult(namespace, name string, owner *domain.MimirRules) domain.RuleSyncResult {
+	return domain.RuleSyncResult{
+		Namespace: namespace,
+		Name:      name,
+		State:     domain.RuleConflict,
+		Error:     fmt.Sprintf("synchronized by MimirRules %s/%s, which targets the same tenant", owner.Namespace, owner.Name),
+	}
+}
+
+// setRuleResult replaces the result of the synchronization of a PrometheusRule in the status of a MimirRules
+// The result is removed if result is nil
+func setRuleResult(mr *domain.MimirRules, namespace, name string, result *domain.RuleSyncResult) {
+	mr.Status.Rules = slices.DeleteFunc(mr.Status.Rules, func(r domain.RuleSyncResult) bool {
+		return r.Namespace == namespace && r.Name == name
+	})
+
+	if result != nil {
+		mr.Status.Rules = append(mr.Status.Rules, *result)
+		sortRuleResults(mr.Status.Rules)
+	}
+}
+
+// hasRuleResult returns whether the status of a MimirRules holds the result of the synchronization of a PrometheusRule
+func hasRuleResult(mr *domain.MimirRules, namespace, name string) bool {
+	return slices.ContainsFunc(mr.Status.Rules, func(r domain.RuleSyncResult) bool {
+		return r.Namespace == namespace && r.Name == name
+	})
+}
+
+// sortRuleResults sorts results by namespace and name of their PrometheusRule
+func sortRuleResults(results []domain.RuleSyncResult) {
+	slices.SortFunc(results, func(a, b domain.RuleSyncResult) int {
+		if c := strings.Compare(a.Namespace, b.Namespace); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
@@ -0,0 +1,45 @@
+package mimirrules
+
+import (
+	"slices"
+	"testing"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestRejectedRules(t *testing.T) {
+	ruler := newFakeRuler(t)
+	r := newTestReconciler(t,
+		newMimirRules("rules", ruler.URL, map[string]string{"team": "a"}),
+		newPrometheusRule("a", map[string]string{"team": "a"}, "A"),
+		newPrometheusRule("b", map[string]string{"team": "a"}, "B"),
+	)
+
+	// The rules of the other PrometheusRules are still pushed when Mimir rejects some of them
+	ruler.setRejected("default_b", true)
+	mr := runReconcile(t, r, "rules")
+
+	if mr.Status.Status != "Failed" {
+		t.Errorf("expected the synchronization to fail, got %s", mr.Status.Status)
+	}
+	if names := ruler.namespaceNames(); !slices.Equal(names, []string{"default_a"}) {
+		t.Errorf("expected the accepted rules to be pushed, got %v", names)
+	}
+	if len(mr.Status.Rules) != 2 || mr.Status.Rules[0].State != domain.RuleSynced ||
+		mr.Status.Rules[1].State != domain.RuleRejected || mr.Status.Rules[1].Error == "" {
+		t.Errorf("expected only the rejected PrometheusRule to be reported as rejected, got %+v", mr.Status.Rules)
+	}
+
+	// The rejected rules are pushed once Mimir accepts them
+	ruler.setRejected("default_b", false)
+	mr = runReconcile(t, r, "rules")
+
+	if mr.Status.Status != "Synced" {
+		t.Errorf("expected the rules to be synced, got %s: %s", mr.Status.Status, mr.Status.Error)
+	}
+	for _, result := range mr.Status.Rules {
+		if result.State != domain.RuleSynced || result.Error != "" {
+			t.Errorf("expected the PrometheusRules to be reported as synced, got %+v", mr.Status.Rules)
+		}
+	}
+}
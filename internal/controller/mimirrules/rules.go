@@ -3,15 +3,19 @@ package mimirrules
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"sort"
 
 	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
 	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
-	"github.com/AmiditeX/mimir-operator/internal/utils"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi/rwrulefmt"
+	"github.com/AmiditeX/mimir-operator/internal/metrics"
 
 	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -35,62 +39,251 @@ func (r *MimirRulesReconciler) syncRulesToRuler(ctx context.Context, mc *mimirap
 		return err
 	}
 
-	// Apply overrides on the PrometheusRules using the properties defined inside the MimirRules
-	applyOverrides(mr.Spec.Overrides, rules)
+	if mr.Status.Digests == nil {
+		mr.Status.Digests = map[string]string{}
+	}
+	recordManagedNamespaces(mr, managedNamespaces(mr))
 
-	// Add external labels to the PrometheusRules
-	applyExternalLabels(mr.Spec.ExternalLabels, rules)
+	// Changes of PrometheusRules are synchronized by a full synchronization until one reaches every namespace
+	mr.Status.FullSyncGeneration = 0
 
-	// Convert the PrometheusRules to a format Mimir understands
-	unpackedRules, err := r.unpackRules(rules)
+	// PrometheusRules also selected by older MimirRules targeting the same tenant are synchronized by those,
+	// so that MimirRules sharing a tenant don't overwrite or delete the rules of each other
+	rivals, err := r.findPrecedingRivals(ctx, mr)
+	if err != nil {
+		return err
+	}
+	var conflicts []domain.RuleSyncResult
+	rules.Items = slices.DeleteFunc(rules.Items, func(rule *prometheus.PrometheusRule) bool {
+		owner := r.claimant(rivals, rule)
+		if owner == nil {
+			return false
+		}
+
+		releaseNamespace(mr, rule.Namespace+"_"+rule.Name)
+		conflicts = append(conflicts, conflictResult(rule.Namespace, rule.Name, owner))
+		return true
+	})
+
+	unpackedRules, desired, invalid, err := r.renderRules(mr, rules)
 	if err != nil {
 		return err
 	}
+	r.publishRenderedOutput(ctx, mr, unpackedRules)
+
+	// List the rules of the tenant in Mimir, to find those changed outside the operator
+	// Mimir answers with a 404 when the tenant has no rules
+	live, err := mc.ListRules(ctx, "")
+	if err != nil && !errors.Is(err, mimirapi.ErrResourceNotFound) {
+		return err
+	}
+
+	// A full synchronization is requested by changing the reconcile-now annotation, it also repairs the drift
+	reconcileNow := mr.Annotations[domain.ReconcileNowAnnotation]
+	fullSync := reconcileNow != "" && reconcileNow != mr.Status.LastHandledReconcileAt
+
+	drifted, err := detectDrift(live, mr.Status.Digests, desired)
+	if err != nil {
+		return err
+	}
+	r.reportDrift(mr, drifted, r.DriftRepair || fullSync)
 
 	// Reset stored prometheus rules
 	mr.Status.RefRules = []string{}
 
 	// Synchronize each Rule on the Mimir Ruler
+	// Invalid rules are not pushed, the rules last pushed for their namespace being left in Mimir
+	toPush := map[string]string{}
 	for namespace, ruleGroup := range unpackedRules {
-		if err := mc.CreateRuleGroupStr(ctx, namespace, ruleGroup); err != nil {
-			return err
-		}
 		mr.Status.RefRules = append(mr.Status.RefRules, namespace)
+
+		if _, ok := invalid[namespace]; ok {
+			continue
+		}
+		if fullSync || r.needsPush(mr, namespace, desired[namespace], drifted) {
+			toPush[namespace] = ruleGroup
+		}
 	}
 	sort.Strings(mr.Status.RefRules)
 
+	// Every namespace is synchronized even if some fail, their errors being reported together
+	failed := mc.CreateRuleGroupsStr(ctx, toPush)
+	for namespace := range toPush {
+		if _, ok := failed[namespace]; !ok {
+			mr.Status.Digests[namespace] = desired[namespace]
+			addManagedNamespace(mr, namespace)
+		}
+	}
+	pushed := len(toPush) - len(failed)
+	for namespace, err := range invalid {
+		failed[namespace] = err
+	}
+
+	// Report the result of each PrometheusRule, rules changed in Mimir and left as is being skipped
+	mr.Status.Rules = make([]domain.RuleSyncResult, 0, len(rules.Items)+len(conflicts))
+	for _, rule := range rules.Items {
+		namespace := rule.Namespace + "_" + rule.Name
+		_, attempted := toPush[namespace]
+		skipped := !attempted && slices.Contains(drifted, namespace)
+		mr.Status.Rules = append(mr.Status.Rules, ruleResult(rule.Namespace, rule.Name, failed[namespace], skipped))
+	}
+	mr.Status.Rules = append(mr.Status.Rules, conflicts...)
+	sortRuleResults(mr.Status.Rules)
+	r.reportConflicts(mr)
+
+	groupCount, ruleCount := countRules(rules)
+	metrics.SetRuleCounts(mr.Namespace, mr.Name, mr.Spec.ID, groupCount, ruleCount)
+	if pushed > 0 {
+		r.Recorder.Eventf(mr, corev1.EventTypeNormal, "RulesPushed", "Pushed the rules of %d namespaces to Mimir, %d namespaces were unchanged", pushed, len(unpackedRules)-len(toPush)-len(invalid))
+	}
+
 	// Find the namespaces on Mimir that are NOT in our list of WANTED rules
 	// Those namespaces might have been created earlier by the operator, but the MimirRules selectors
 	// have changed since then, making those namespaces unwanted and in need of deletion.
-	namespaces, err := r.diffRuleNamespaces(ctx, mc, unpackedRules, mr.Spec.ID)
+	// Only the namespaces created by this MimirRules are deleted, those of other MimirRules or loaded by other
+	// means on the same tenant are left untouched
+	namespaces := diffRuleNamespaces(live, unpackedRules, mr.Status.ManagedNamespaces)
+
+	// Delete each of those unwanted namespace
+	failedDeletions := mc.DeleteNamespaces(ctx, namespaces)
+	for _, namespace := range namespaces {
+		if err, ok := failedDeletions[namespace]; ok {
+			failed[namespace] = err
+			continue
+		}
+		delete(mr.Status.Digests, namespace)
+		removeManagedNamespace(mr, namespace)
+		r.Recorder.Eventf(mr, corev1.EventTypeNormal, "RulesDeleted", "Deleted the rules of namespace %s from Mimir", namespace)
+	}
+
+	if fullSync && len(failed) == 0 {
+		mr.Status.LastHandledReconcileAt = reconcileNow
+	}
+
+	// Forget the namespaces that are neither wanted nor in Mimir anymore
+	for _, namespace := range slices.Clone(mr.Status.ManagedNamespaces) {
+		_, wanted := unpackedRules[namespace]
+		_, exists := live[namespace]
+		if !wanted && !exists {
+			delete(mr.Status.Digests, namespace)
+			removeManagedNamespace(mr, namespace)
+		}
+	}
+
+	mr.Status.FullSyncGeneration = mr.Generation
+	mr.Status.FailedNamespaces = sortedNamespaces(failed)
+
+	return joinNamespaceErrors(failed)
+}
+
+// countRules returns the number of rule groups and rules of PrometheusRules
+func countRules(rules *prometheus.PrometheusRuleList) (int, int) {
+	groupCount, ruleCount := 0, 0
+	for _, rule := range rules.Items {
+		groupCount += len(rule.Spec.Groups)
+		for _, group := range rule.Spec.Groups {
+			ruleCount += len(group.Rules)
+		}
+	}
+
+	return groupCount, ruleCount
+}
+
+// renderRules converts PrometheusRules to the rules of a MimirRules in the format Mimir understands, by Mimir namespace,
+// and returns them with their digests
+// The rules are validated before being sent to Mimir, the namespaces of invalid rules being returned with their errors
+// instead of their digests
+func (r *MimirRulesReconciler) renderRules(mr *domain.MimirRules, rules *prometheus.PrometheusRuleList) (map[string]string, map[string]string, map[string]error, error) {
+	// The errors of the rules point at the PrometheusRules as they are defined, before the overrides remove some rules
+	sources := ruleSources(rules)
+
+	// Apply overrides on the PrometheusRules using the properties defined inside the MimirRules
+	applyOverrides(mr.Spec.Overrides, rules)
+
+	// Add external labels to the PrometheusRules
+	applyExternalLabels(mr.Spec.ExternalLabels, rules)
+
+	// Convert the PrometheusRules to a format Mimir understands
+	unpackedRules, err := r.unpackRules(rules)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
-	// Synchronize each of those unwanted namespace with empty rule content to trigger a deletion
-	for _, namespace := range namespaces {
-		if err := mc.DeleteNamespace(ctx, namespace); err != nil {
-			return err
+	invalid := validateRules(rules, unpackedRules, sources)
+
+	valid := make(map[string]string, len(unpackedRules))
+	for namespace, content := range unpackedRules {
+		if _, ok := invalid[namespace]; !ok {
+			valid[namespace] = content
 		}
 	}
 
-	return nil
+	digests, err := digestRules(valid)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return unpackedRules, digests, invalid, nil
+}
+
+// sortedNamespaces returns the Mimir namespaces that failed to synchronize, sorted
+func sortedNamespaces(failed map[string]error) []string {
+	namespaces := make([]string, 0, len(failed))
+	for namespace := range failed {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// joinNamespaceErrors joins the errors of the Mimir namespaces that failed to synchronize, sorted by namespace
+func joinNamespaceErrors(failed map[string]error) error {
+	namespaces := sortedNamespaces(failed)
+
+	errs := make([]error, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		errs = append(errs, failed[namespace])
+	}
+
+	return errors.Join(errs...)
 }
 
-// deleteRulesForTenant deletes all the rules from Mimir for a specific tenant
+// needsPush returns whether the rules of a namespace must be pushed to Mimir, digest being the digest of its rules
+// Rules unchanged since they were last pushed are skipped, unless they were changed in Mimir outside the operator
+func (r *MimirRulesReconciler) needsPush(mr *domain.MimirRules, namespace, digest string, drifted []string) bool {
+	applied, ok := mr.Status.Digests[namespace]
+	if !ok || applied != digest {
+		return true
+	}
+
+	// Without drift repair, rules changed in Mimir are only overwritten once they change in the cluster too
+	return r.DriftRepair && slices.Contains(drifted, namespace)
+}
+
+// deleteRulesForTenant deletes the rules of a MimirRules from Mimir
+// Only the namespaces created by the MimirRules are deleted, the other namespaces of the tenant are left untouched
 func (r *MimirRulesReconciler) deleteRulesForTenant(ctx context.Context, mr *domain.MimirRules, mc *mimirapi.MimirClient) error {
-	// List all the rules and namespaces for the MimirRules
-	rules, err := mc.ListRulesElement(ctx, mr.Spec.ID)
+	// The namespaces other MimirRules took over hold their rules now, they are left in Mimir
+	takenOver, err := r.namespacesTakenOver(ctx, mr)
 	if err != nil {
 		return err
 	}
-
-	// For each rule, synchronize its namespace with empty rules to trigger a deletion
-	for _, rule := range rules {
-		if err := mc.DeleteNamespace(ctx, rule.Namespace); err != nil {
-			return err
+	namespaces := slices.DeleteFunc(slices.Clone(managedNamespaces(mr)), func(namespace string) bool {
+		return slices.Contains(takenOver, namespace)
+	})
+
+	failed := mc.DeleteNamespaces(ctx, namespaces)
+	for namespace, err := range failed {
+		// Namespaces already deleted from Mimir are fine
+		if errors.Is(err, mimirapi.ErrResourceNotFound) {
+			delete(failed, namespace)
 		}
 	}
+	if err := joinNamespaceErrors(failed); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(mr, corev1.EventTypeNormal, "RulesDeleted", "Deleted the rules of %d namespaces of tenant %s from Mimir", len(namespaces), mr.Spec.ID)
 
 	return nil
 }
@@ -259,26 +452,19 @@ func (r *MimirRulesReconciler) unpackRules(list *prometheus.PrometheusRuleList)
 	return results, nil
 }
 
-// diffRuleNamespaces returns Rule namespaces that are currently in Mimir for the tenant but not in the ruleMap
-func (r *MimirRulesReconciler) diffRuleNamespaces(ctx context.Context, mc *mimirapi.MimirClient, ruleMap map[string]string, tenant string) ([]string, error) {
+// diffRuleNamespaces returns the managed Rule namespaces that are currently in Mimir for the tenant but not in the ruleMap
+func diffRuleNamespaces(live map[string][]rwrulefmt.RuleGroup, ruleMap map[string]string, managed []string) []string {
 	var namespaces []string
 
-	// List all the rules and namespaces for the tenant
-	rules, err := mc.ListRulesElement(ctx, tenant)
-	if err != nil {
-		return nil, err
-	}
-
-	// For each namespace in Mimir, check if it's in the ruleMap
-	for _, rule := range rules {
-		_, ok := ruleMap[rule.Namespace]
-		if !ok {
-			namespaces = append(namespaces, rule.Namespace)
+	// For each managed namespace in Mimir, check if it's in the ruleMap
+	for namespace := range live {
+		if _, ok := ruleMap[namespace]; !ok && slices.Contains(managed, namespace) {
+			namespaces = append(namespaces, namespace)
 		}
 	}
+	sort.Strings(namespaces)
 
-	// There might have been multiple rules in the same namespace, remove duplicates
-	return utils.RemoveDuplicate(namespaces), nil
+	return namespaces
 }
 
 // applyExternalLabels adds a list of labels to every PrometheusRule in a list
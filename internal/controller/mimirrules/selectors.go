@@ -0,0 +1,126 @@
+package mimirrules
+
+import (
+	"slices"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+const (
+	// prometheusRuleRefIndex indexes MimirRules by the PrometheusRules they synchronized, as "<namespace>_<name>"
+	prometheusRuleRefIndex = "status.refRules"
+
+	// selectorKeyIndex indexes MimirRules by the label keys a PrometheusRule must have to be selected by one of
+	// their selectors, MimirRules with a selector that doesn't require any label being indexed under anyLabelKey
+	selectorKeyIndex = "spec.rules.selectors.keys"
+
+	// anyLabelKey is the value of selectorKeyIndex of the MimirRules that might select a PrometheusRule whatever its labels
+	// It isn't a valid label key, so it can't collide with the keys of the other selectors
+	anyLabelKey = "*"
+)
+
+// selectorKeys returns the values under which a MimirRules is indexed in selectorKeyIndex
+// A selector only matches the PrometheusRules having every key it requires (through matchLabels or an In, Exists,
+// Gt or Lt expression), so the MimirRules is indexed by those keys. Selectors only made of NotIn and DoesNotExist
+// expressions, or without any requirement, match PrometheusRules without any label and are indexed under anyLabelKey.
+// Invalid selectors never match and are not indexed.
+func selectorKeys(mr *domain.MimirRules) []string {
+	var keys []string
+	for _, labelSelector := range mr.Spec.Rules.Selectors {
+		if labelSelector == nil { // A nil selector matches nothing
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil {
+			continue
+		}
+
+		requirements, _ := selector.Requirements()
+		required := false
+		for _, requirement := range requirements {
+			switch requirement.Operator() {
+			case selection.NotIn, selection.NotEquals, selection.DoesNotExist:
+				continue
+			}
+			required = true
+			if !slices.Contains(keys, requirement.Key()) {
+				keys = append(keys, requirement.Key())
+			}
+		}
+
+		if !required && !slices.Contains(keys, anyLabelKey) {
+			keys = append(keys, anyLabelKey)
+		}
+	}
+
+	return keys
+}
+
+// selectorCache holds the parsed selectors of each MimirRules, so that PrometheusRules can be matched
+// against every MimirRules without parsing their selectors on every event
+type selectorCache struct {
+	mu        sync.Mutex
+	selectors map[types.NamespacedName]parsedSelectors
+}
+
+// parsedSelectors are the selectors of a generation of a MimirRules
+type parsedSelectors struct {
+	uid        types.UID
+	generation int64
+	selectors  []labels.Selector
+}
+
+// matches returns whether one of the selectors of a MimirRules selects a PrometheusRule with the given labels
+func (c *selectorCache) matches(mr *domain.MimirRules, ruleLabels map[string]string) bool {
+	for _, selector := range c.get(mr) {
+		if selector.Matches(labels.Set(ruleLabels)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// get returns the parsed selectors of a MimirRules, parsing them if it changed since they were cached
+// Invalid selectors are ignored, the error being reported by the synchronization of the MimirRules
+func (c *selectorCache) get(mr *domain.MimirRules) []labels.Selector {
+	key := types.NamespacedName{Namespace: mr.Namespace, Name: mr.Name}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.selectors[key]; ok && cached.uid == mr.UID && cached.generation == mr.Generation {
+		return cached.selectors
+	}
+
+	selectors := make([]labels.Selector, 0, len(mr.Spec.Rules.Selectors))
+	for _, labelSelector := range mr.Spec.Rules.Selectors {
+		selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil {
+			continue
+		}
+		selectors = append(selectors, selector)
+	}
+
+	if c.selectors == nil {
+		c.selectors = map[types.NamespacedName]parsedSelectors{}
+	}
+	c.selectors[key] = parsedSelectors{uid: mr.UID, generation: mr.Generation, selectors: selectors}
+
+	return selectors
+}
+
+// forget drops the selectors of a deleted MimirRules
+func (c *selectorCache) forget(key types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.selectors, key)
+}
@@ -0,0 +1,66 @@
+package mimirrules
+
+import (
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestSelectorKeys(t *testing.T) {
+	tests := map[string]struct {
+		selectors []*metav1.LabelSelector
+		keys      []string
+	}{
+		"match labels": {
+			selectors: []*metav1.LabelSelector{{MatchLabels: map[string]string{"team": "a", "env": "prod"}}},
+			keys:      []string{"env", "team"},
+		},
+		"expressions": {
+			selectors: []*metav1.LabelSelector{{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "group", Operator: metav1.LabelSelectorOpIn, Values: []string{"node"}},
+				{Key: "version", Operator: metav1.LabelSelectorOpExists},
+				{Key: "legacy", Operator: metav1.LabelSelectorOpDoesNotExist},
+			}}},
+			keys: []string{"group", "version"},
+		},
+		"only negative expressions": {
+			selectors: []*metav1.LabelSelector{{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"b"}},
+			}}},
+			keys: []string{anyLabelKey},
+		},
+		"empty selector": {
+			selectors: []*metav1.LabelSelector{{}},
+			keys:      []string{anyLabelKey},
+		},
+		"nil and invalid selectors": {
+			selectors: []*metav1.LabelSelector{nil, {MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: metav1.LabelSelectorOpIn},
+			}}},
+			keys: nil,
+		},
+		"several selectors": {
+			selectors: []*metav1.LabelSelector{
+				{MatchLabels: map[string]string{"team": "a"}},
+				{MatchLabels: map[string]string{"team": "b"}},
+				{},
+			},
+			keys: []string{anyLabelKey, "team"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mr := &domain.MimirRules{Spec: domain.MimirRulesSpec{Rules: &domain.Rules{Selectors: test.selectors}}}
+
+			keys := selectorKeys(mr)
+			slices.Sort(keys)
+			if !slices.Equal(keys, test.keys) {
+				t.Errorf("expected keys %v, got %v", test.keys, keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package mimirrules
+
+import (
+	"fmt"
+
+	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+)
+
+// sourceGroup is a group of a PrometheusRule as it is defined, with the names of its rules
+type sourceGroup struct {
+	name  string
+	rules []string
+}
+
+// ruleSources returns the groups of PrometheusRules as they are defined by Mimir namespace, so that the errors found
+// once the overrides are applied point at them
+func ruleSources(rules *prometheus.PrometheusRuleList) map[string][]sourceGroup {
+	sources := make(map[string][]sourceGroup, len(rules.Items))
+	for _, rule := range rules.Items {
+		groups := make([]sourceGroup, 0, len(rule.Spec.Groups))
+		for _, group := range rule.Spec.Groups {
+			names := make([]string, 0, len(group.Rules))
+			for _, r := range group.Rules {
+				names = append(names, ruleName(r))
+			}
+			groups = append(groups, sourceGroup{name: group.Name, rules: names})
+		}
+		sources[rule.Namespace+"_"+rule.Name] = groups
+	}
+
+	return sources
+}
+
+// validateRules validates the rendered rules of PrometheusRules, and returns the errors of the invalid ones by Mimir namespace
+// The errors point at the PrometheusRule, the group and the rule, with their index in the PrometheusRule as it is defined
+func validateRules(rules *prometheus.PrometheusRuleList, unpackedRules map[string]string, sources map[string][]sourceGroup) map[string]error {
+	invalid := map[string]error{}
+	for _, rule := range rules.Items {
+		namespace := rule.Namespace + "_" + rule.Name
+		if err := mimirapi.ValidateRuleGroups(unpackedRules[namespace], sourceLocator(rule, sources[namespace])); err != nil {
+			invalid[namespace] = fmt.Errorf("PrometheusRule %s/%s: %w", rule.Namespace, rule.Name, err)
+		}
+	}
+
+	return invalid
+}
+
+// sourceLocator returns a locator pointing at the groups and the rules of a PrometheusRule as it is defined, rule
+// being the PrometheusRule as it is rendered
+// The overrides may remove rules, and the groups left empty. The groups and rules rendered are found in the source
+// by their name and their rank among those of the same name.
+func sourceLocator(rule *prometheus.PrometheusRule, source []sourceGroup) mimirapi.RuleLocator {
+	groupNames := make([]string, 0, len(source))
+	for _, group := range source {
+		groupNames = append(groupNames, group.name)
+	}
+
+	return func(g, i int) string {
+		sg, sr := g, i
+		if g < len(rule.Spec.Groups) {
+			group := rule.Spec.Groups[g]
+			rendered := make([]string, 0, g)
+			for _, previous := range rule.Spec.Groups[:g] {
+				rendered = append(rendered, previous.Name)
+			}
+			if index := nthIndex(groupNames, group.Name, count(rendered, group.Name)); index >= 0 {
+				sg = index
+			}
+
+			if i >= 0 && i < len(group.Rules) && sg < len(source) {
+				name := ruleName(group.Rules[i])
+				rendered = make([]string, 0, i)
+				for _, previous := range group.Rules[:i] {
+					rendered = append(rendered, ruleName(previous))
+				}
+				if index := nthIndex(source[sg].rules, name, count(rendered, name)); index >= 0 {
+					sr = index
+				}
+			}
+		}
+
+		if i < 0 {
+			return fmt.Sprintf("spec.groups[%d]", sg)
+		}
+		return fmt.Sprintf("spec.groups[%d].rules[%d]", sg, sr)
+	}
+}
+
+// ruleName returns the name of a rule of a PrometheusRule, the alert of alerting rules or the series recorded
+func ruleName(rule prometheus.Rule) string {
+	if rule.Alert != "" {
+		return rule.Alert
+	}
+	return rule.Record
+}
+
+// count returns the number of occurrences of name in names
+func count(names []string, name string) int {
+	n := 0
+	for _, candidate := range names {
+		if candidate == name {
+			n++
+		}
+	}
+	return n
+}
+
+// nthIndex returns the index of the occurrence of name in names with the given rank, or -1 if there are fewer
+func nthIndex(names []string, name string, rank int) int {
+	for i, candidate := range names {
+		if candidate != name {
+			continue
+		}
+		if rank == 0 {
+			return i
+		}
+		rank--
+	}
+	return -1
+}
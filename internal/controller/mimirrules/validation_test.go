@@ -0,0 +1,51 @@
+package mimirrules
+
+import (
+	"strings"
+	"testing"
+
+	prometheus "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestValidationErrorsPointAtTheSource(t *testing.T) {
+	mr := newMimirRules("rules", "http://mimir", map[string]string{"team": "a"})
+	mr.Spec.Overrides = map[string]domain.Override{"Disabled": {Disable: true}}
+	r := newTestReconciler(t, mr)
+
+	invalidFor := prometheus.Duration("5x")
+	rule := newPrometheusRule("alerts", map[string]string{"team": "a"})
+	rule.Spec.Groups = []prometheus.RuleGroup{
+		// Left empty by the overrides, the group isn't rendered
+		{Name: "disabled", Rules: []prometheus.Rule{{Alert: "Disabled", Expr: intstr.FromString("up == 0")}}},
+		{Name: "a", Rules: []prometheus.Rule{
+			{Alert: "Disabled", Expr: intstr.FromString("up == 0")},
+			{Alert: "Valid", Expr: intstr.FromString("up == 0")},
+			{Alert: "Broken", Expr: intstr.FromString("up == 0"), For: &invalidFor},
+			{Alert: "Valid", Expr: intstr.FromString("up == 0")},
+		}},
+		{Name: "b", Rules: []prometheus.Rule{{Alert: "Other", Expr: intstr.FromString("up == 0"), For: &invalidFor}}},
+	}
+
+	_, _, invalid, err := r.renderRules(mr, &prometheus.PrometheusRuleList{Items: []*prometheus.PrometheusRule{rule}})
+	if err != nil {
+		t.Fatalf("failed to render the rules: %v", err)
+	}
+
+	got := invalid["default_alerts"]
+	if got == nil {
+		t.Fatalf("expected the rules to be invalid")
+	}
+	for _, expected := range []string{
+		"PrometheusRule default/alerts: invalid rules: ",
+		`group "a", rule "Broken" (spec.groups[1].rules[2]): invalid 'for' duration`,
+		`group "a", rule "Valid" (spec.groups[1].rules[3]): rule is repeated with the same labels, first defined at spec.groups[1].rules[1]`,
+		`group "b", rule "Other" (spec.groups[2].rules[0]): invalid 'for' duration`,
+	} {
+		if !strings.Contains(got.Error(), expected) {
+			t.Errorf("expected the error to contain %q, got %q", expected, got.Error())
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// Statuses reported by the sync status gauge, the gauge is 1 for the current status of a resource and 0 for the others
+var statuses = []string{"Synced", "Failed", "Degraded", "Suspended", "DryRun"}
+
+var (
+	// lastSuccessfulSync is the timestamp of the last successful synchronization of a resource
+	lastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mimir_operator_last_successful_sync_timestamp_seconds",
+		Help: "Timestamp of the last successful synchronization of a resource to Mimir.",
+	}, []string{"kind", "namespace", "name", "tenant"})
+
+	// syncStatus reports the status of the last synchronization of a resource
+	syncStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mimir_operator_sync_status",
+		Help: "Status of the last synchronization of a resource to Mimir, 1 for the current status and 0 for the others.",
+	}, []string{"kind", "namespace", "name", "tenant", "status"})
+
+	// ruleGroups is the number of rule groups pushed to Mimir for a MimirRules
+	ruleGroups = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mimir_operator_rule_groups",
+		Help: "Number of rule groups pushed to Mimir by a MimirRules.",
+	}, []string{"namespace", "name", "tenant"})
+
+	// drift reports the drift found between Mimir and the state last synchronized by the operator
+	drift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mimir_operator_drift_detected",
+		Help: "Number of Mimir namespaces, or 1 for an Alertmanager configuration, changed outside the operator during the last synchronization of a resource.",
+	}, []string{"kind", "namespace", "name", "tenant"})
+
+	// rules is the number of rules pushed to Mimir for a MimirRules
+	rules = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mimir_operator_rules",
+		Help: "Number of rules pushed to Mimir by a MimirRules.",
+	}, []string{"namespace", "name", "tenant"})
+)
+
+// tenants holds the tenant each resource was last reported for, so that the series of the previous
+// tenant of a resource are dropped when it changes
+var tenants sync.Map
+
+func init() {
+	// Metrics are exposed on the metrics endpoint of the manager
+	metrics.Registry.MustRegister(lastSuccessfulSync, syncStatus, drift, ruleGroups, rules)
+}
+
+// SetSyncStatus records the status of the synchronization of a resource
+// The timestamp of the last successful synchronization is updated when the status is "Synced"
+func SetSyncStatus(kind, namespace, name, tenant, status string) {
+	trackTenant(kind, namespace, name, tenant)
+
+	for _, s := range statuses {
+		value := 0.0
+		if s == status {
+			value = 1
+		}
+		syncStatus.WithLabelValues(kind, namespace, name, tenant, s).Set(value)
+	}
+
+	if status == "Synced" {
+		lastSuccessfulSync.WithLabelValues(kind, namespace, name, tenant).SetToCurrentTime()
+	}
+}
+
+// SetDrift records the number of objects of a resource that were changed in Mimir outside the operator
+func SetDrift(kind, namespace, name, tenant string, drifted int) {
+	trackTenant(kind, namespace, name, tenant)
+
+	drift.WithLabelValues(kind, namespace, name, tenant).Set(float64(drifted))
+}
+
+// SetRuleCounts records the number of rule groups and rules pushed to Mimir by a MimirRules
+func SetRuleCounts(namespace, name, tenant string, groupCount, ruleCount int) {
+	trackTenant(domain.MimirRulesKind, namespace, name, tenant)
+
+	ruleGroups.WithLabelValues(namespace, name, tenant).Set(float64(groupCount))
+	rules.WithLabelValues(namespace, name, tenant).Set(float64(ruleCount))
+}
+
+// Forget drops the series of a resource, it must be called once the resource is deleted
+func Forget(kind, namespace, name string) {
+	tenants.Delete(resourceKey(kind, namespace, name))
+
+	labels := prometheus.Labels{"kind": kind, "namespace": namespace, "name": name}
+	lastSuccessfulSync.DeletePartialMatch(labels)
+	syncStatus.DeletePartialMatch(labels)
+	drift.DeletePartialMatch(labels)
+
+	// Rule counts are only reported for MimirRules, they have no kind label
+	if kind == domain.MimirRulesKind {
+		ruleGroups.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "name": name})
+		rules.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "name": name})
+	}
+}
+
+// trackTenant drops the series of a resource if they were reported for another tenant
+func trackTenant(kind, namespace, name, tenant string) {
+	key := resourceKey(kind, namespace, name)
+	if previous, ok := tenants.Load(key); ok && previous.(string) != tenant {
+		Forget(kind, namespace, name)
+	}
+	tenants.Store(key, tenant)
+}
+
+func resourceKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
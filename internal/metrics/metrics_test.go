@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+func TestSetSyncStatus(t *testing.T) {
+	SetSyncStatus(domain.MimirRulesKind, "team", "status", "tenant", "Failed")
+	SetSyncStatus(domain.MimirRulesKind, "team", "status", "tenant", "Synced")
+
+	for _, status := range statuses {
+		expected := 0.0
+		if status == "Synced" {
+			expected = 1
+		}
+		if got := testutil.ToFloat64(syncStatus.WithLabelValues(domain.MimirRulesKind, "team", "status", "tenant", status)); got != expected {
+			t.Errorf("expected status %s to be %v, got %v", status, expected, got)
+		}
+	}
+	if got := testutil.ToFloat64(lastSuccessfulSync.WithLabelValues(domain.MimirRulesKind, "team", "status", "tenant")); got == 0 {
+		t.Errorf("expected the timestamp of the last successful synchronization to be set")
+	}
+
+	Forget(domain.MimirRulesKind, "team", "status")
+}
+
+func TestSetRuleCounts(t *testing.T) {
+	SetRuleCounts("team", "counts", "tenant", 2, 5)
+
+	if got := testutil.ToFloat64(ruleGroups.WithLabelValues("team", "counts", "tenant")); got != 2 {
+		t.Errorf("expected 2 rule groups, got %v", got)
+	}
+	if got := testutil.ToFloat64(rules.WithLabelValues("team", "counts", "tenant")); got != 5 {
+		t.Errorf("expected 5 rules, got %v", got)
+	}
+
+	Forget(domain.MimirRulesKind, "team", "counts")
+}
+
+func TestForget(t *testing.T) {
+	SetSyncStatus(domain.MimirRulesKind, "team", "forget", "tenant", "Synced")
+	SetRuleCounts("team", "forget", "tenant", 1, 1)
+	SetSyncStatus(domain.MimirAlertManagerConfigKind, "team", "forget", "tenant", "Synced")
+
+	Forget(domain.MimirRulesKind, "team", "forget")
+
+	if got := testutil.CollectAndCount(syncStatus); got != len(statuses) {
+		t.Errorf("expected only the series of the other resource to be left, got %d series", got)
+	}
+	if got := testutil.CollectAndCount(ruleGroups) + testutil.CollectAndCount(rules); got != 0 {
+		t.Errorf("expected the rule counts to be dropped, got %d series", got)
+	}
+
+	Forget(domain.MimirAlertManagerConfigKind, "team", "forget")
+}
+
+func TestTenantChange(t *testing.T) {
+	SetSyncStatus(domain.MimirRulesKind, "team", "tenant", "first", "Synced")
+	SetSyncStatus(domain.MimirRulesKind, "team", "tenant", "second", "Synced")
+
+	if got := testutil.CollectAndCount(lastSuccessfulSync); got != 1 {
+		t.Errorf("expected the series of the previous tenant to be dropped, got %d series", got)
+	}
+
+	Forget(domain.MimirRulesKind, "team", "tenant")
+}
@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mimirrandgenxyzv1alpha1 "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+	"github.com/AmiditeX/mimir-operator/internal/controller/mimirapi"
+)
+
+// ErrAuthSettings is returned when the authentication settings of a resource can't be read
+var ErrAuthSettings = errors.New("failed to extract authentication settings")
+
+const (
+	// maxMessageItems is the number of items listed in a condition or an event, the others only being counted
+	maxMessageItems = 10
+
+	// maxMessageLength is the length in bytes above which the message of a condition is truncated
+	maxMessageLength = 2048
+)
+
+// JoinItems returns the first items of a list separated by commas, followed by the count of the ones left out
+func JoinItems(items []string) string {
+	if len(items) <= maxMessageItems {
+		return strings.Join(items, ", ")
+	}
+
+	return fmt.Sprintf("%s … and %d more", strings.Join(items[:maxMessageItems], ", "), len(items)-maxMessageItems)
+}
+
+// truncateMessage returns the beginning of a message too long to be kept in a condition, followed by the count
+// of the bytes left out
+func truncateMessage(message string) string {
+	if len(message) <= maxMessageLength {
+		return message
+	}
+
+	end := maxMessageLength
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+
+	return fmt.Sprintf("%s … and %d more bytes", message[:end], len(message)-end)
+}
+
+// SyncReason returns the reason of the outcome of a synchronization, err being the error it returned
+func SyncReason(err error) string {
+	switch {
+	case err == nil:
+		return mimirrandgenxyzv1alpha1.ReasonSynced
+	case errors.Is(err, mimirapi.ErrCircuitOpen):
+		return mimirrandgenxyzv1alpha1.ReasonCircuitOpen
+	case errors.Is(err, mimirapi.ErrUnauthorized), errors.Is(err, ErrAuthSettings):
+		return mimirrandgenxyzv1alpha1.ReasonUnauthorized
+	case errors.Is(err, mimirapi.ErrBadRequest), errors.Is(err, mimirapi.ErrInvalidRules):
+		return mimirrandgenxyzv1alpha1.ReasonInvalid
+	default:
+		return mimirrandgenxyzv1alpha1.ReasonSyncFailed
+	}
+}
+
+// SetSyncConditions updates the conditions of a resource from the outcome of its synchronization to Mimir
+// generation is the generation of the resource that was synchronized
+func SetSyncConditions(conditions *[]metav1.Condition, generation int64, err error) {
+	reason := SyncReason(err)
+	message := "Synchronized to Mimir"
+	if err != nil {
+		message = truncateMessage(err.Error())
+	}
+
+	// The other conditions are only True when they describe the cause of the failure
+	set := func(conditionType string, active bool) {
+		condition := metav1.Condition{
+			Type:               conditionType,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             mimirrandgenxyzv1alpha1.ReasonNoFailure,
+		}
+		if active {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = reason
+			condition.Message = message
+		}
+		meta.SetStatusCondition(conditions, condition)
+	}
+
+	// Ready and Synced carry the reason of the failure when they are False
+	for _, conditionType := range []string{mimirrandgenxyzv1alpha1.ConditionReady, mimirrandgenxyzv1alpha1.ConditionSynced} {
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             conditionStatus(err == nil),
+			ObservedGeneration: generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	set(mimirrandgenxyzv1alpha1.ConditionDegraded, reason == mimirrandgenxyzv1alpha1.ReasonCircuitOpen)
+	set(mimirrandgenxyzv1alpha1.ConditionAuthFailed, reason == mimirrandgenxyzv1alpha1.ReasonUnauthorized)
+	set(mimirrandgenxyzv1alpha1.ConditionValidationFailed, reason == mimirrandgenxyzv1alpha1.ReasonInvalid)
+}
+
+func conditionStatus(value bool) metav1.ConditionStatus {
+	if value {
+		return metav1.ConditionTrue
+	}
+	return metav1.ConditionFalse
+}
+
+// SetDriftCondition updates the Drifted condition of a resource from the drift found during its synchronization
+// drifted lists what was changed in Mimir outside the operator, and repaired whether it was overwritten since
+func SetDriftCondition(conditions *[]metav1.Condition, generation int64, drifted []string, repaired bool) {
+	condition := metav1.Condition{
+		Type:               mimirrandgenxyzv1alpha1.ConditionDrifted,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             mimirrandgenxyzv1alpha1.ReasonNoFailure,
+	}
+
+	switch {
+	case len(drifted) > 0 && repaired:
+		condition.Reason = mimirrandgenxyzv1alpha1.ReasonDriftRepaired
+		condition.Message = truncateMessage("Repaired changes made in Mimir outside the operator: " + JoinItems(drifted))
+	case len(drifted) > 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = mimirrandgenxyzv1alpha1.ReasonDriftDetected
+		condition.Message = truncateMessage("Changes were made in Mimir outside the operator: " + JoinItems(drifted))
+	}
+
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// SetConflictCondition updates the Conflict condition of a resource from the conflicts found during its synchronization
+// conflicts lists what is synchronized by other resources targeting the same tenant
+func SetConflictCondition(conditions *[]metav1.Condition, generation int64, conflicts []string) {
+	condition := metav1.Condition{
+		Type:               mimirrandgenxyzv1alpha1.ConditionConflict,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             mimirrandgenxyzv1alpha1.ReasonNoFailure,
+	}
+
+	if len(conflicts) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = mimirrandgenxyzv1alpha1.ReasonConflict
+		condition.Message = truncateMessage("Synchronized by other resources targeting the same tenant: " + JoinItems(conflicts))
+	}
+
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// SetMigratingCondition updates the Migrating condition of a resource from the removal of its content from
+// the tenants it targeted before, err being the error of that removal
+func SetMigratingCondition(conditions *[]metav1.Condition, generation int64, err error) {
+	condition := metav1.Condition{
+		Type:               mimirrandgenxyzv1alpha1.ConditionMigrating,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             mimirrandgenxyzv1alpha1.ReasonNoFailure,
+	}
+
+	if err != nil {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = mimirrandgenxyzv1alpha1.ReasonCleanupFailed
+		condition.Message = truncateMessage(err.Error())
+	}
+
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// SetSuspendedCondition updates the Suspended condition of a resource, message describing why it is suspended
+// The resource is not suspended if message is empty, otherwise it is not Ready either
+func SetSuspendedCondition(conditions *[]metav1.Condition, generation int64, message string) {
+	condition := metav1.Condition{
+		Type:               mimirrandgenxyzv1alpha1.ConditionSuspended,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             mimirrandgenxyzv1alpha1.ReasonNoFailure,
+	}
+
+	if message != "" {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = mimirrandgenxyzv1alpha1.ReasonSuspended
+		condition.Message = message
+
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               mimirrandgenxyzv1alpha1.ConditionReady,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             mimirrandgenxyzv1alpha1.ReasonSuspended,
+			Message:            message,
+		})
+	}
+
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// SuspendedMessage is the message of the Suspended condition of a resource
+const SuspendedMessage = "Synchronization to Mimir is suspended"
+
+// SuspendedDeletionMessage is the message of the event emitted when a suspended resource is deleted
+const SuspendedDeletionMessage = "Deleted while its synchronization to Mimir was suspended, its content is left in Mimir whatever its deletion policy"
+
+// SetDryRunCondition updates the DryRun condition of a resource, plan describing the changes it would make in Mimir
+// The resource is not in dry-run mode if plan is empty, otherwise it is not Ready either as nothing was synchronized
+func SetDryRunCondition(conditions *[]metav1.Condition, generation int64, plan string) {
+	condition := metav1.Condition{
+		Type:               mimirrandgenxyzv1alpha1.ConditionDryRun,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             mimirrandgenxyzv1alpha1.ReasonNoFailure,
+	}
+
+	if plan != "" {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = mimirrandgenxyzv1alpha1.ReasonDryRun
+		condition.Message = truncateMessage(plan)
+
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               mimirrandgenxyzv1alpha1.ConditionReady,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             mimirrandgenxyzv1alpha1.ReasonDryRun,
+			Message:            "Changes are only planned in dry-run mode",
+		})
+	}
+
+	meta.SetStatusCondition(conditions, condition)
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// namespaces returns n namespace names
+func namespaces(n int) []string {
+	items := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		items = append(items, fmt.Sprintf("namespace-%d", i))
+	}
+
+	return items
+}
+
+func TestJoinItems(t *testing.T) {
+	tests := map[string]struct {
+		items    []string
+		expected string
+	}{
+		"empty":       {expected: ""},
+		"few items":   {items: []string{"a", "b"}, expected: "a, b"},
+		"bound":       {items: namespaces(maxMessageItems), expected: strings.Join(namespaces(maxMessageItems), ", ")},
+		"above bound": {items: namespaces(maxMessageItems + 3), expected: strings.Join(namespaces(maxMessageItems), ", ") + " … and 3 more"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := JoinItems(test.items); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	tests := map[string]struct {
+		message  string
+		expected string
+	}{
+		"short": {message: "failed", expected: "failed"},
+		"bound": {message: strings.Repeat("x", maxMessageLength), expected: strings.Repeat("x", maxMessageLength)},
+		"too long": {
+			message:  strings.Repeat("x", maxMessageLength+5),
+			expected: strings.Repeat("x", maxMessageLength) + " … and 5 more bytes",
+		},
+		"multi-byte character at the bound": {
+			message:  strings.Repeat("x", maxMessageLength-1) + "é",
+			expected: strings.Repeat("x", maxMessageLength-1) + " … and 2 more bytes",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := truncateMessage(test.message); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestConditionMessagesAreBounded(t *testing.T) {
+	many := namespaces(1000)
+	long := errors.New(strings.Repeat("failed to synchronize the rules; ", 1000))
+
+	tests := map[string]struct {
+		set           func(conditions *[]metav1.Condition)
+		conditionType string
+		suffix        string
+	}{
+		"sync": {
+			set:           func(conditions *[]metav1.Condition) { SetSyncConditions(conditions, 1, long) },
+			conditionType: domain.ConditionSynced,
+			suffix:        "more bytes",
+		},
+		"drift": {
+			set:           func(conditions *[]metav1.Condition) { SetDriftCondition(conditions, 1, many, false) },
+			conditionType: domain.ConditionDrifted,
+			suffix:        "… and 990 more",
+		},
+		"repaired drift": {
+			set:           func(conditions *[]metav1.Condition) { SetDriftCondition(conditions, 1, many, true) },
+			conditionType: domain.ConditionDrifted,
+			suffix:        "… and 990 more",
+		},
+		"conflict": {
+			set:           func(conditions *[]metav1.Condition) { SetConflictCondition(conditions, 1, many) },
+			conditionType: domain.ConditionConflict,
+			suffix:        "… and 990 more",
+		},
+		"migrating": {
+			set:           func(conditions *[]metav1.Condition) { SetMigratingCondition(conditions, 1, long) },
+			conditionType: domain.ConditionMigrating,
+			suffix:        "more bytes",
+		},
+		"dry run": {
+			set:           func(conditions *[]metav1.Condition) { SetDryRunCondition(conditions, 1, long.Error()) },
+			conditionType: domain.ConditionDryRun,
+			suffix:        "more bytes",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var conditions []metav1.Condition
+			test.set(&conditions)
+
+			condition := meta.FindStatusCondition(conditions, test.conditionType)
+			if condition == nil {
+				t.Fatalf("expected the %s condition to be set", test.conditionType)
+			}
+			if len(condition.Message) > maxMessageLength+len(" … and 99999 more bytes") {
+				t.Errorf("expected the message to be bounded, got %d bytes", len(condition.Message))
+			}
+			if !strings.HasSuffix(condition.Message, test.suffix) {
+				t.Errorf("expected the message to end with %q, got %q", test.suffix, condition.Message)
+			}
+		})
+	}
+}
+
+func TestSetDriftCondition(t *testing.T) {
+	tests := map[string]struct {
+		drifted  []string
+		repaired bool
+		status   metav1.ConditionStatus
+		reason   string
+	}{
+		"no drift": {status: metav1.ConditionFalse, reason: domain.ReasonNoFailure},
+		"detected": {drifted: []string{"a"}, status: metav1.ConditionTrue, reason: domain.ReasonDriftDetected},
+		"repaired": {drifted: []string{"a"}, repaired: true, status: metav1.ConditionFalse, reason: domain.ReasonDriftRepaired},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var conditions []metav1.Condition
+			SetDriftCondition(&conditions, 1, test.drifted, test.repaired)
+
+			condition := meta.FindStatusCondition(conditions, domain.ConditionDrifted)
+			if condition == nil || condition.Status != test.status || condition.Reason != test.reason {
+				t.Errorf("expected the condition to be %s with reason %s, got %+v", test.status, test.reason, condition)
+			}
+		})
+	}
+}
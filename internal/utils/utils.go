@@ -3,6 +3,8 @@ package utils
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	mimirrandgenxyzv1alpha1 "github.com/AmiditeX/mimir-operator/api/v1alpha1"
 
@@ -14,6 +16,27 @@ type Authentication struct {
 	Username string
 	Key      string
 	Token    string
+	OAuth2   *OAuth2
+	TLS      TLS
+}
+
+// OAuth2 holds the OAuth2 client credentials settings extracted from a CRD, with the client secret read from its Secret
+type OAuth2 struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams map[string]string
+	TLS            TLS
+}
+
+// TLS holds the TLS settings extracted from a CRD, with the PEM data read from Secrets and ConfigMaps
+type TLS struct {
+	CA                 []byte
+	Cert               []byte
+	Key                []byte
+	ServerName         string
+	InsecureSkipVerify bool
 }
 
 // RemoveDuplicate removes duplicate values from a slice
@@ -30,7 +53,7 @@ func RemoveDuplicate[T string | int](sliceList []T) []T {
 }
 
 // FindSecretByRef returns a Kubernetes secret referenced using a secret name and namespace
-func FindSecretByRef(ctx context.Context, c client.Client, secretName, secretNamespace string) (*v1.Secret, error) {
+func FindSecretByRef(ctx context.Context, c client.Reader, secretName, secretNamespace string) (*v1.Secret, error) {
 	secret := &v1.Secret{}
 
 	objectKey := client.ObjectKey{
@@ -47,7 +70,7 @@ func FindSecretByRef(ctx context.Context, c client.Client, secretName, secretNam
 }
 
 // FindValueByKeyInSecret returns the value for a given key in a Secret
-func FindValueByKeyInSecret(ctx context.Context, c client.Client, secretName, secretNamespace, key string) (string, error) {
+func FindValueByKeyInSecret(ctx context.Context, c client.Reader, secretName, secretNamespace, key string) (string, error) {
 	secret, err := FindSecretByRef(ctx, c, secretName, secretNamespace)
 	if err != nil {
 		return "", err
@@ -61,16 +84,218 @@ func FindValueByKeyInSecret(ctx context.Context, c client.Client, secretName, se
 	return string(value), nil
 }
 
+// Referrer describes the resource referencing Secrets and ConfigMaps
+// Namespace is the namespace in which they are looked up when a reference doesn't set one
+type Referrer struct {
+	Kind      string
+	Namespace string
+}
+
+// namespaceOf returns the namespace of a reference, the namespace of the referrer being used if it is empty
+func (r Referrer) namespaceOf(namespace string) string {
+	if namespace != "" {
+		return namespace
+	}
+	return r.Namespace
+}
+
+// FindValueBySelector returns the value referenced by a SecretKeySelector, defaultKey being used if the selector has no key
+// References to Secrets of another namespace than the one of the referrer must be allowed by a MimirReferenceGrant
+func FindValueBySelector(ctx context.Context, c client.Reader, selector *mimirrandgenxyzv1alpha1.SecretKeySelector, referrer Referrer, defaultKey string) (string, error) {
+	namespace := referrer.namespaceOf(selector.Namespace)
+	if err := CheckReferenceGrant(ctx, c, referrer, mimirrandgenxyzv1alpha1.SecretKind, selector.Name, namespace); err != nil {
+		return "", err
+	}
+
+	key := selector.Key
+	if key == "" {
+		key = defaultKey
+	}
+
+	return FindValueByKeyInSecret(ctx, c, selector.Name, namespace, key)
+}
+
+// FindValueByConfigMapSelector returns the value referenced by a ConfigMapKeySelector, defaultKey being used if the selector has no key
+// References to ConfigMaps of another namespace than the one of the referrer must be allowed by a MimirReferenceGrant
+func FindValueByConfigMapSelector(ctx context.Context, c client.Reader, selector *mimirrandgenxyzv1alpha1.ConfigMapKeySelector, referrer Referrer, defaultKey string) (string, error) {
+	namespace := referrer.namespaceOf(selector.Namespace)
+	if err := CheckReferenceGrant(ctx, c, referrer, mimirrandgenxyzv1alpha1.ConfigMapKind, selector.Name, namespace); err != nil {
+		return "", err
+	}
+
+	key := selector.Key
+	if key == "" {
+		key = defaultKey
+	}
+
+	return FindValueByKeyInConfigMap(ctx, c, selector.Name, namespace, key)
+}
+
+// CheckReferenceGrant returns an error if the referrer isn't allowed to reference the given Secret or ConfigMap
+// Resources of the namespace of the referrer can always be referenced, other ones require a MimirReferenceGrant
+// in their namespace allowing the kind and namespace of the referrer
+func CheckReferenceGrant(ctx context.Context, c client.Reader, referrer Referrer, kind, name, namespace string) error {
+	if namespace == referrer.Namespace {
+		return nil
+	}
+
+	grants := &mimirrandgenxyzv1alpha1.MimirReferenceGrantList{}
+	if err := c.List(ctx, grants, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list reference grants in namespace %s: %w", namespace, err)
+	}
+
+	for _, grant := range grants.Items {
+		if grantAllows(&grant.Spec, referrer, kind, name) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("reference from %s in namespace %s to %s %s/%s is not allowed by any MimirReferenceGrant", referrer.Kind, referrer.Namespace, strings.ToLower(kind), namespace, name)
+}
+
+// grantAllows returns true if a grant allows the referrer to reference the resource with the given kind and name
+func grantAllows(grant *mimirrandgenxyzv1alpha1.MimirReferenceGrantSpec, referrer Referrer, kind, name string) bool {
+	fromAllowed := slices.ContainsFunc(grant.From, func(from mimirrandgenxyzv1alpha1.ReferenceGrantFrom) bool {
+		if from.Kind != referrer.Kind {
+			return false
+		}
+
+		// ClusterMimirConnections are not namespaced, the namespace of the grant source doesn't apply to them
+		return referrer.Kind == mimirrandgenxyzv1alpha1.ClusterMimirConnectionKind || from.Namespace == referrer.Namespace
+	})
+
+	toAllowed := slices.ContainsFunc(grant.To, func(to mimirrandgenxyzv1alpha1.ReferenceGrantTo) bool {
+		toKind := to.Kind
+		if toKind == "" {
+			toKind = mimirrandgenxyzv1alpha1.SecretKind
+		}
+		return toKind == kind && (to.Name == "" || to.Name == name)
+	})
+
+	return fromAllowed && toAllowed
+}
+
+// FindValueByKeyInConfigMap returns the value for a given key in a ConfigMap
+func FindValueByKeyInConfigMap(ctx context.Context, c client.Reader, name, namespace, key string) (string, error) {
+	configMap := &v1.ConfigMap{}
+
+	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, configMap)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve configmap %s/%s: %w", namespace, name, err)
+	}
+
+	value, ok := configMap.Data[key]
+	if !ok {
+		return "", fmt.Errorf("couldn't find  key '%s' in configmap %s/%s", key, namespace, name)
+	}
+
+	return value, nil
+}
+
+// FindValueInSecretOrConfigMap returns the value referenced by a SecretOrConfigMap, defaultKey being used if the reference has no key
+func FindValueInSecretOrConfigMap(ctx context.Context, c client.Reader, ref *mimirrandgenxyzv1alpha1.SecretOrConfigMap, referrer Referrer, defaultKey string) ([]byte, error) {
+	switch {
+	case ref.Secret != nil && ref.ConfigMap != nil:
+		return nil, fmt.Errorf("only one of secret or configMap can be set")
+	case ref.Secret != nil:
+		value, err := FindValueBySelector(ctx, c, ref.Secret, referrer, defaultKey)
+		return []byte(value), err
+	case ref.ConfigMap != nil:
+		value, err := FindValueByConfigMapSelector(ctx, c, ref.ConfigMap, referrer, defaultKey)
+		return []byte(value), err
+	default:
+		return nil, fmt.Errorf("one of secret or configMap must be set")
+	}
+}
+
+// ExtractTLS returns the TLS settings from a CRD TLS structure, reading the certificates and keys it references
+// This function is safe to call with the 'tlsConfig' parameter set to 'nil' and will return empty settings
+func ExtractTLS(ctx context.Context, c client.Reader, tlsConfig *mimirrandgenxyzv1alpha1.TLSConfig, referrer Referrer) (TLS, error) {
+	if tlsConfig == nil {
+		return TLS{}, nil
+	}
+
+	result := TLS{
+		ServerName:         tlsConfig.ServerName,
+		InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+	}
+
+	var err error
+	if tlsConfig.CA != nil {
+		if result.CA, err = FindValueInSecretOrConfigMap(ctx, c, tlsConfig.CA, referrer, "ca.crt"); err != nil {
+			return TLS{}, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+	}
+
+	if tlsConfig.Cert != nil {
+		if result.Cert, err = FindValueInSecretOrConfigMap(ctx, c, tlsConfig.Cert, referrer, "tls.crt"); err != nil {
+			return TLS{}, fmt.Errorf("failed to read client certificate: %w", err)
+		}
+	}
+
+	if tlsConfig.KeySecret != nil {
+		key, err := FindValueBySelector(ctx, c, tlsConfig.KeySecret, referrer, "tls.key")
+		if err != nil {
+			return TLS{}, fmt.Errorf("failed to read client certificate key: %w", err)
+		}
+		result.Key = []byte(key)
+	}
+
+	return result, nil
+}
+
+// ExtractHeaders returns the HTTP headers from a list of CRD headers, reading the values stored in Secrets
+func ExtractHeaders(ctx context.Context, c client.Reader, headers []mimirrandgenxyzv1alpha1.Header, referrer Referrer) (map[string]string, error) {
+	result := make(map[string]string, len(headers))
+
+	for _, header := range headers {
+		if header.SecretKeyRef == nil {
+			result[header.Name] = header.Value
+			continue
+		}
+
+		if header.SecretKeyRef.Key == "" {
+			return nil, fmt.Errorf("the secret reference of header '%s' has no key", header.Name)
+		}
+
+		value, err := FindValueBySelector(ctx, c, header.SecretKeyRef, referrer, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value of header '%s': %w", header.Name, err)
+		}
+		result[header.Name] = value
+	}
+
+	return result, nil
+}
+
 // ExtractAuth returns an internal authentication structure from a CRD authentication structure
+// Secrets and ConfigMaps are looked up in the namespace of the referrer, unless a reference explicitly targets another namespace
 // The returned authentication structure can be used by the package to generate authenticated command calls
 // This function is safe to call with the 'auth' parameter set to 'nil' and will return a 'nil' auth structure and no error
 // This is often needed if no authentication was provided by the user creating the CRD, as can be
 // called without any authentication enabled, thus having no need for a mandatory authentication field in the CRDs
-func ExtractAuth(ctx context.Context, client client.Client, auth *mimirrandgenxyzv1alpha1.Auth, namespace string) (*Authentication, error) {
+func ExtractAuth(ctx context.Context, client client.Reader, auth *mimirrandgenxyzv1alpha1.Auth, referrer Referrer) (*Authentication, error) {
 	if auth == nil { // No authentication settings were provided
 		return &Authentication{}, nil
 	}
 
+	authentication, err := extractCredentials(ctx, client, auth, referrer)
+	if err != nil {
+		return nil, err
+	}
+
+	// TLS settings are independent of the authentication scheme
+	authentication.TLS, err = ExtractTLS(ctx, client, auth.TLS, referrer)
+	if err != nil {
+		return nil, err
+	}
+
+	return authentication, nil
+}
+
+// extractCredentials returns the credentials of the authentication scheme selected in a CRD authentication structure
+func extractCredentials(ctx context.Context, client client.Reader, auth *mimirrandgenxyzv1alpha1.Auth, referrer Referrer) (*Authentication, error) {
+
 	if auth.Token != "" { // Token plaintext value has precedence over everything else
 		return &Authentication{
 			Token: auth.Token,
@@ -78,7 +303,7 @@ func ExtractAuth(ctx context.Context, client client.Client, auth *mimirrandgenxy
 	}
 
 	if auth.TokenSecretRef != nil { // Token secret reference has precedence over auth/key scheme
-		token, err := FindValueByKeyInSecret(ctx, client, auth.TokenSecretRef.Name, namespace, "token")
+		token, err := FindValueBySelector(ctx, client, auth.TokenSecretRef, referrer, "token")
 		if err != nil {
 			return nil, err
 		}
@@ -88,24 +313,55 @@ func ExtractAuth(ctx context.Context, client client.Client, auth *mimirrandgenxy
 		}, nil
 	}
 
-	if auth.Key != "" { // Plaintext key has precedence
+	if auth.OAuth2 != nil { // OAuth2 has precedence over auth/key scheme
+		secret, err := FindValueBySelector(ctx, client, &auth.OAuth2.ClientSecret, referrer, "clientSecret")
+		if err != nil {
+			return nil, err
+		}
+		tlsSettings, err := ExtractTLS(ctx, client, auth.OAuth2.TLS, referrer)
+		if err != nil {
+			return nil, err
+		}
+
 		return &Authentication{
-			Username: auth.User,
-			Key:      auth.Key,
+			OAuth2: &OAuth2{
+				TokenURL:       auth.OAuth2.TokenURL,
+				ClientID:       auth.OAuth2.ClientID,
+				ClientSecret:   secret,
+				Scopes:         auth.OAuth2.Scopes,
+				EndpointParams: auth.OAuth2.EndpointParams,
+				TLS:            tlsSettings,
+			},
 		}, nil
 	}
 
-	if auth.KeySecretRef != nil {
-		key, err := FindValueByKeyInSecret(ctx, client, auth.KeySecretRef.Name, namespace, "key")
+	if auth.Key == "" && auth.KeySecretRef == nil {
+		return &Authentication{}, nil // Auth settings were provided but all uninitialized
+	}
+
+	username := auth.User
+	if username == "" && auth.UserSecretRef != nil {
+		user, err := FindValueBySelector(ctx, client, auth.UserSecretRef, referrer, "user")
 		if err != nil {
 			return nil, err
 		}
+		username = user
+	}
 
+	if auth.Key != "" { // Plaintext key has precedence
 		return &Authentication{
-			Username: auth.User,
-			Key:      key,
+			Username: username,
+			Key:      auth.Key,
 		}, nil
 	}
 
-	return &Authentication{}, nil // Auth settings were provided but all uninitialized
+	key, err := FindValueBySelector(ctx, client, auth.KeySecretRef, referrer, "key")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authentication{
+		Username: username,
+		Key:      key,
+	}, nil
 }
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	domain "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// referrer is the resource referencing Secrets and ConfigMaps in the tests
+var referrer = Referrer{Kind: domain.MimirRulesKind, Namespace: "team"}
+
+// newTestClient returns a fake client holding the given objects
+func newTestClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, domain.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatalf("failed to build scheme: %v", err)
+		}
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func newSecret(namespace, name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}, Data: map[string][]byte{}}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+
+	return secret
+}
+
+func newConfigMap(namespace, name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}, Data: data}
+}
+
+func TestExtractTLS(t *testing.T) {
+	objs := []client.Object{
+		newConfigMap("team", "ca", map[string]string{"ca.crt": "configmap ca", "bundle": "bundle"}),
+		newSecret("team", "client", map[string]string{"ca.crt": "secret ca", "tls.crt": "cert", "tls.key": "key"}),
+		newConfigMap("shared", "ca", map[string]string{"ca.crt": "shared ca"}),
+	}
+
+	tests := map[string]struct {
+		tls      *domain.TLSConfig
+		expected TLS
+		invalid  bool
+	}{
+		"nil": {},
+		"options only": {
+			tls:      &domain.TLSConfig{ServerName: "mimir.example", InsecureSkipVerify: true},
+			expected: TLS{ServerName: "mimir.example", InsecureSkipVerify: true},
+		},
+		"ca from configmap": {
+			tls:      &domain.TLSConfig{CA: &domain.SecretOrConfigMap{ConfigMap: &domain.ConfigMapKeySelector{Name: "ca"}}},
+			expected: TLS{CA: []byte("configmap ca")},
+		},
+		"ca from configmap key": {
+			tls:      &domain.TLSConfig{CA: &domain.SecretOrConfigMap{ConfigMap: &domain.ConfigMapKeySelector{Name: "ca", Key: "bundle"}}},
+			expected: TLS{CA: []byte("bundle")},
+		},
+		"client certificate from secret": {
+			tls: &domain.TLSConfig{
+				CA:        &domain.SecretOrConfigMap{Secret: &domain.SecretKeySelector{Name: "client"}},
+				Cert:      &domain.SecretOrConfigMap{Secret: &domain.SecretKeySelector{Name: "client"}},
+				KeySecret: &domain.SecretKeySelector{Name: "client"},
+			},
+			expected: TLS{CA: []byte("secret ca"), Cert: []byte("cert"), Key: []byte("key")},
+		},
+		"secret and configmap": {
+			tls: &domain.TLSConfig{CA: &domain.SecretOrConfigMap{
+				Secret:    &domain.SecretKeySelector{Name: "client"},
+				ConfigMap: &domain.ConfigMapKeySelector{Name: "ca"},
+			}},
+			invalid: true,
+		},
+		"neither secret nor configmap": {
+			tls:     &domain.TLSConfig{CA: &domain.SecretOrConfigMap{}},
+			invalid: true,
+		},
+		"missing key": {
+			tls:     &domain.TLSConfig{Cert: &domain.SecretOrConfigMap{ConfigMap: &domain.ConfigMapKeySelector{Name: "ca"}}},
+			invalid: true,
+		},
+		"cross-namespace configmap without grant": {
+			tls:     &domain.TLSConfig{CA: &domain.SecretOrConfigMap{ConfigMap: &domain.ConfigMapKeySelector{Name: "ca", Namespace: "shared"}}},
+			invalid: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := ExtractTLS(context.Background(), newTestClient(t, objs...), test.tls, referrer)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(result.CA) != string(test.expected.CA) || string(result.Cert) != string(test.expected.Cert) || string(result.Key) != string(test.expected.Key) ||
+				result.ServerName != test.expected.ServerName || result.InsecureSkipVerify != test.expected.InsecureSkipVerify {
+				t.Errorf("expected %+v, got %+v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestExtractHeaders(t *testing.T) {
+	objs := []client.Object{newSecret("team", "headers", map[string]string{"scope": "secret"})}
+
+	tests := map[string]struct {
+		headers  []domain.Header
+		expected map[string]string
+		invalid  bool
+	}{
+		"value": {
+			headers:  []domain.Header{{Name: "X-Scope", Value: "value"}},
+			expected: map[string]string{"X-Scope": "value"},
+		},
+		"secret over value": {
+			headers:  []domain.Header{{Name: "X-Scope", Value: "value", SecretKeyRef: &domain.SecretKeySelector{Name: "headers", Key: "scope"}}},
+			expected: map[string]string{"X-Scope": "secret"},
+		},
+		"last header wins": {
+			headers:  []domain.Header{{Name: "X-Scope", Value: "first"}, {Name: "X-Scope", Value: "second"}},
+			expected: map[string]string{"X-Scope": "second"},
+		},
+		"secret without key": {
+			headers: []domain.Header{{Name: "X-Scope", SecretKeyRef: &domain.SecretKeySelector{Name: "headers"}}},
+			invalid: true,
+		},
+		"missing secret": {
+			headers: []domain.Header{{Name: "X-Scope", SecretKeyRef: &domain.SecretKeySelector{Name: "missing", Key: "scope"}}},
+			invalid: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			headers, err := ExtractHeaders(context.Background(), newTestClient(t, objs...), test.headers, referrer)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(headers) != len(test.expected) {
+				t.Fatalf("expected headers %v, got %v", test.expected, headers)
+			}
+			for name, value := range test.expected {
+				if headers[name] != value {
+					t.Errorf("expected header %s to be %q, got %q", name, value, headers[name])
+				}
+			}
+		})
+	}
+}
+
+func TestGrantAllows(t *testing.T) {
+	tests := map[string]struct {
+		grant    domain.MimirReferenceGrantSpec
+		referrer Referrer
+		kind     string
+		name     string
+		expected bool
+	}{
+		"secret": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.MimirRulesKind, Namespace: "team"}}, To: []domain.ReferenceGrantTo{{Kind: domain.SecretKind}}},
+			referrer: referrer, kind: domain.SecretKind, name: "token",
+			expected: true,
+		},
+		"secret by default": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.MimirRulesKind, Namespace: "team"}}, To: []domain.ReferenceGrantTo{{}}},
+			referrer: referrer, kind: domain.SecretKind, name: "token",
+			expected: true,
+		},
+		"configmap": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.MimirRulesKind, Namespace: "team"}}, To: []domain.ReferenceGrantTo{{Kind: domain.ConfigMapKind, Name: "ca"}}},
+			referrer: referrer, kind: domain.ConfigMapKind, name: "ca",
+			expected: true,
+		},
+		"configmap not covered by a secret grant": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.MimirRulesKind, Namespace: "team"}}, To: []domain.ReferenceGrantTo{{}}},
+			referrer: referrer, kind: domain.ConfigMapKind, name: "ca",
+		},
+		"secret not covered by a configmap grant": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.MimirRulesKind, Namespace: "team"}}, To: []domain.ReferenceGrantTo{{Kind: domain.ConfigMapKind}}},
+			referrer: referrer, kind: domain.SecretKind, name: "token",
+		},
+		"other name": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.MimirRulesKind, Namespace: "team"}}, To: []domain.ReferenceGrantTo{{Name: "other"}}},
+			referrer: referrer, kind: domain.SecretKind, name: "token",
+		},
+		"other referrer kind": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.MimirAlertManagerConfigKind, Namespace: "team"}}, To: []domain.ReferenceGrantTo{{}}},
+			referrer: referrer, kind: domain.SecretKind, name: "token",
+		},
+		"other referrer namespace": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.MimirRulesKind, Namespace: "other"}}, To: []domain.ReferenceGrantTo{{}}},
+			referrer: referrer, kind: domain.SecretKind, name: "token",
+		},
+		"cluster connection in any namespace": {
+			grant:    domain.MimirReferenceGrantSpec{From: []domain.ReferenceGrantFrom{{Kind: domain.ClusterMimirConnectionKind}}, To: []domain.ReferenceGrantTo{{}}},
+			referrer: Referrer{Kind: domain.ClusterMimirConnectionKind, Namespace: "operator"}, kind: domain.SecretKind, name: "token",
+			expected: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := grantAllows(&test.grant, test.referrer, test.kind, test.name); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckReferenceGrant(t *testing.T) {
+	grant := &domain.MimirReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shared", Name: "grant"},
+		Spec: domain.MimirReferenceGrantSpec{
+			From: []domain.ReferenceGrantFrom{{Kind: domain.MimirRulesKind, Namespace: "team"}},
+			To:   []domain.ReferenceGrantTo{{Kind: domain.ConfigMapKind, Name: "ca"}},
+		},
+	}
+	c := newTestClient(t, grant)
+
+	tests := map[string]struct {
+		kind, name, namespace string
+		allowed               bool
+	}{
+		"same namespace":        {kind: domain.SecretKind, name: "token", namespace: "team", allowed: true},
+		"granted configmap":     {kind: domain.ConfigMapKind, name: "ca", namespace: "shared", allowed: true},
+		"other configmap":       {kind: domain.ConfigMapKind, name: "other", namespace: "shared"},
+		"secret with same name": {kind: domain.SecretKind, name: "ca", namespace: "shared"},
+		"namespace without grant": {
+			kind: domain.ConfigMapKind, name: "ca", namespace: "other",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := CheckReferenceGrant(context.Background(), c, referrer, test.kind, test.name, test.namespace)
+			if test.allowed && err != nil {
+				t.Errorf("expected the reference to be allowed, got %v", err)
+			}
+			if !test.allowed && err == nil {
+				t.Errorf("expected the reference to be refused")
+			}
+		})
+	}
+}
+
+func TestStripMetadata(t *testing.T) {
+	obj := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{
+		Namespace:     "team",
+		Name:          "token",
+		Labels:        map[string]string{"app": "mimir"},
+		Annotations:   map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"},
+		ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+	}}
+
+	result, err := stripMetadata(obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stripped := result.(*metav1.PartialObjectMetadata)
+	if stripped.Annotations != nil || stripped.ManagedFields != nil {
+		t.Errorf("expected the annotations and managed fields to be dropped, got %+v", stripped.ObjectMeta)
+	}
+	if stripped.Namespace != "team" || stripped.Name != "token" || stripped.Labels["app"] != "mimir" {
+		t.Errorf("expected the namespace, name and labels to be kept, got %+v", stripped.ObjectMeta)
+	}
+}
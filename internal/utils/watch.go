@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	mimirrandgenxyzv1alpha1 "github.com/AmiditeX/mimir-operator/api/v1alpha1"
+)
+
+// NewReferenceCache returns a cache holding the metadata of the Secrets and ConfigMaps, and adds it to the manager
+// Every Secret and ConfigMap is watched, whatever its labels, so that any referenced one synchronizes the resources
+// depending on it as soon as it changes. Their content is never cached: it is read from the API server when used,
+// and the metadata is stripped down to what is needed to look up the resources referencing them in ReferenceIndex.
+func NewReferenceCache(mgr manager.Manager) (cache.Cache, error) {
+	references, err := cache.New(mgr.GetConfig(), cache.Options{
+		HTTPClient:       mgr.GetHTTPClient(),
+		Scheme:           mgr.GetScheme(),
+		Mapper:           mgr.GetRESTMapper(),
+		DefaultTransform: stripMetadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return references, mgr.Add(references)
+}
+
+// stripMetadata drops the annotations and managed fields of the objects held by the reference cache
+// Only their namespace and name are used to find the resources referencing them
+func stripMetadata(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetAnnotations(nil)
+		accessor.SetManagedFields(nil)
+	}
+
+	return obj, nil
+}
+
+// LabelExists returns a selector matching the objects having a label, whatever its value
+func LabelExists(key string) labels.Selector {
+	requirement, err := labels.NewRequirement(key, selection.Exists, nil)
+	if err != nil { // The label keys are constants, they are always valid
+		panic(err)
+	}
+
+	return labels.NewSelector().Add(*requirement)
+}
+
+// ReferenceReader returns a reader getting Secrets and ConfigMaps from apiReader, and any other object from c
+// The cache of the manager doesn't hold every Secret and ConfigMap, so those referenced by the resources are
+// read from the API server
+func ReferenceReader(c, apiReader client.Reader) client.Reader {
+	return &referenceReader{cached: c, api: apiReader}
+}
+
+type referenceReader struct {
+	cached client.Reader
+	api    client.Reader
+}
+
+func (r *referenceReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	switch obj.(type) {
+	case *corev1.Secret, *corev1.ConfigMap:
+		return r.api.Get(ctx, key, obj, opts...)
+	default:
+		return r.cached.Get(ctx, key, obj, opts...)
+	}
+}
+
+func (r *referenceReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	switch list.(type) {
+	case *corev1.SecretList, *corev1.ConfigMapList:
+		return r.api.List(ctx, list, opts...)
+	default:
+		return r.cached.List(ctx, list, opts...)
+	}
+}
+
+// ReferenceMapFunc returns the requests of the resources depending on a Secret or a ConfigMap of the given kind
+type ReferenceMapFunc func(ctx context.Context, kind string, obj client.Object) []reconcile.Request
+
+// referenceKinds are the kinds of the resources watched for the resources referencing them
+var referenceKinds = []string{mimirrandgenxyzv1alpha1.SecretKind, mimirrandgenxyzv1alpha1.ConfigMapKind}
+
+// WatchReferences sets up the watches synchronizing resources again when a Secret or a ConfigMap they reference changes,
+// or when a MimirReferenceGrant allowing a cross-namespace reference changes
+// Only the metadata of the Secrets and ConfigMaps held by the reference cache is watched, forReference returning
+// the requests of the resources depending on one of them, if any. Nothing is watched if references is nil.
+func WatchReferences(b *builder.Builder, references cache.Cache, forReference ReferenceMapFunc) *builder.Builder {
+	if references == nil {
+		return b
+	}
+
+	for _, kind := range referenceKinds {
+		kind := kind
+		b = b.WatchesRawSource(
+			source.Kind(references, referenceMetadata(kind)),
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return forReference(ctx, kind, obj)
+			}))
+	}
+
+	return b.Watches(
+		&mimirrandgenxyzv1alpha1.MimirReferenceGrant{},
+		handler.EnqueueRequestsFromMapFunc(enqueueGrantDependents(references, forReference)))
+}
+
+// referenceMetadata returns an empty object used to watch the metadata of the resources of a kind
+func referenceMetadata(kind string) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+
+	return obj
+}
+
+// enqueueGrantDependents returns a function applying forReference to every watched Secret and ConfigMap covered by
+// a MimirReferenceGrant
+// It is used to synchronize resources again when a grant allowing their cross-namespace references changes
+func enqueueGrantDependents(references client.Reader, forReference ReferenceMapFunc) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		grant, ok := obj.(*mimirrandgenxyzv1alpha1.MimirReferenceGrant)
+		if !ok {
+			return []reconcile.Request{}
+		}
+
+		var requests []reconcile.Request
+		for _, kind := range referenceKinds {
+			objects := &metav1.PartialObjectMetadataList{}
+			objects.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind + "List"))
+			if err := references.List(ctx, objects, client.InNamespace(grant.Namespace)); err != nil {
+				log.FromContext(ctx).Error(err, "failed to list resources covered by reference grant", "grant", client.ObjectKeyFromObject(grant), "kind", kind)
+				continue
+			}
+
+			for i := range objects.Items {
+				if !grantCovers(grant, kind, objects.Items[i].Name) {
+					continue
+				}
+				for _, request := range forReference(ctx, kind, &objects.Items[i]) {
+					if !slices.Contains(requests, request) {
+						requests = append(requests, request)
+					}
+				}
+			}
+		}
+
+		return requests
+	}
+}
+
+// grantCovers returns true if a grant lists the resource with the given kind and name as a resource that can be referenced
+func grantCovers(grant *mimirrandgenxyzv1alpha1.MimirReferenceGrant, kind, name string) bool {
+	return slices.ContainsFunc(grant.Spec.To, func(to mimirrandgenxyzv1alpha1.ReferenceGrantTo) bool {
+		toKind := to.Kind
+		if toKind == "" {
+			toKind = mimirrandgenxyzv1alpha1.SecretKind
+		}
+		return toKind == kind && (to.Name == "" || to.Name == name)
+	})
+}